@@ -1,16 +1,19 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"spitha/datagen/datagen/logger"
-	"syscall"
+	"spitha/datagen/datagen/value"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/kardianos/osext"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v2"
 )
 
 // var Config *ConfigConfig
@@ -20,111 +23,554 @@ import (
 **                       Read external config                        **
 **                                                                   **
 ***********************************************************************/
-// In order to handle empty values, a string format is necessary, since the default value for integers is 0.
+// Numbers, durations and sizes are typed; see types.go for the accepted units
+// and defaultConfig for the value used when a key is missing or empty.
 type ConfigConfig struct {
-	BootstrapServer string         `yaml:"bootstrap-server"`
-	Producer        ProducerConfig `yaml:"producer"`
-	Topic           TopicConfig    `yaml:"topic"`
-	Datagen         DatagenConfig  `yaml:"datagen"`
+	BootstrapServer string          `yaml:"bootstrap-server"`
+	Clusters        []ClusterConfig `yaml:"clusters"` // named clusters, instead of bootstrap-server
+	Producer        ProducerConfig  `yaml:"producer"`
+	Topic           TopicConfig     `yaml:"topic"`
+	Datagen         DatagenConfig   `yaml:"datagen"`
+	Control         ControlConfig   `yaml:"control"`
+	Sink            SinkConfig      `yaml:"sink"`
+	Export          ExportConfig    `yaml:"export"`
+
+	lines   map[string]int    // YAML path -> line, set by decodeYAML
+	sources map[string]string // YAML path -> env or flag that overrode it
+
+	refs      map[string]string // YAML path -> ${env:...} or ${file:...} reference it was resolved from
+	file      string            // absolute path of the YAML file, empty without one
+	overrides Overrides         // command-line values, re-applied on reload
+}
+
+// ClusterConfig is a named cluster to produce to. The sasl and tls of its
+// producer block replace the ones of the top-level producer block when set.
+type ClusterConfig struct {
+	Name            string `yaml:"name"`
+	BootstrapServer string `yaml:"bootstrap-server"`
+	Producer        struct {
+		Sasl SaslConfig `yaml:"sasl"`
+		Tls  TlsConfig  `yaml:"tls"`
+	} `yaml:"producer"`
 }
 
 type ProducerConfig struct {
-	MaxMessageBytes string `yaml:"max-message-bytes"`
-	Lingers         string `yaml:"lingers"`
-	CompressionType string `yaml:"compression-type"`
-	ClientId        string `yaml:"client-id"`        // producer client-id
-	TransactionalID string `yaml:"transactional-id"` // producer transactional-id
-	SchemaRegistry  struct {
+	MaxMessageBytes   ByteSize `yaml:"max-message-bytes"`
+	Lingers           Duration `yaml:"lingers"`
+	CompressionType   string   `yaml:"compression-type"`
+	ClientId          string   `yaml:"client-id"`        // producer client-id
+	TransactionalID   string   `yaml:"transactional-id"` // producer transactional-id
+	Acks              string   `yaml:"acks"`             // 0, 1, all
+	EnableIdempotence *bool    `yaml:"enable-idempotence"`
+	MaxInFlight       int      `yaml:"max-in-flight"`
+	RecordRetries     *int     `yaml:"record-retries"`
+	DeliveryTimeout   Duration `yaml:"delivery-timeout"`
+	RequestTimeout    Duration `yaml:"request-timeout"`
+	MaxBufferedBytes  ByteSize `yaml:"max-buffered-bytes"`
+	SchemaRegistry    struct {
 		Server struct {
-			Urls     string `yaml:"urls"`
-			Username string `yaml:"username"`
-			Password string `yaml:"password"`
+			Urls     string            `yaml:"urls"`
+			Username string            `yaml:"username"`
+			Password string            `yaml:"password" secret:"true"`
+			Token    string            `yaml:"token" secret:"true"`   // static bearer token
+			Oauth    OauthConfig       `yaml:"oauth"`                 // bearer token from a client credentials grant
+			Headers  map[string]string `yaml:"headers" secret:"true"` // added to every request
+			Timeout  Duration          `yaml:"timeout"`               // per request
+			Tls      TlsConfig         `yaml:"tls"`
 		} `yaml:"server"`
-		Subject string `yaml:"subject"`
-		Type    string `yaml:"type"`
+		Subject  string `yaml:"subject"`
+		Type     string `yaml:"type"`      // avro, protobuf, json
+		SchemaId int    `yaml:"schema-id"` // pre-registered schema, instead of registering one
+		Version  string `yaml:"version"`   // pre-registered version of subject, a number or latest
 	} `yaml:"schema-registry"`
-	Sasl struct {
-		Mechanism          string `yaml:"mechanism"` // sasl, plain
-		Username           string `yaml:"username"`
-		Password           string `yaml:"password"`
-		AwsAccessKeyId     string `yaml:"aws-access-key-id"` // aws iam
-		AwsSecretAccessKey string `yaml:"aws-secret-access-key"`
-		ClientId           string `yaml:"client-id"` // oatuh
-		ClientSecret       string `yaml:"client-secret"`
-		TokenEndpoint      string `yaml:"token-endpoint"`
-		KerberosConfig     string `yaml:"kerberos-config-path"` // kerberos
-		KeyTab             string `yaml:"keytab-path"`
-		Realm              string `yaml:"realm"`
-		Servicename        string `yaml:"servicename"`
-	} `yaml:"sasl"`
-	Tls struct {
-		Certfile   string `yaml:"certfile"`
-		Keyfile    string `yaml:"keyfile"`
-		Cafile     string `yaml:"cafile"`
-		SkipVerify bool   `yaml:"skipverify"`
-	} `yaml:"tls"`
+	Sasl SaslConfig `yaml:"sasl"`
+	Tls  TlsConfig  `yaml:"tls"`
+}
+
+// SaslConfig is the SASL authentication of a connection.
+type SaslConfig struct {
+	Mechanism          string            `yaml:"mechanism"` // sasl, plain
+	Username           string            `yaml:"username"`
+	Password           string            `yaml:"password" secret:"true"`
+	AwsAccessKeyId     string            `yaml:"aws-access-key-id"` // aws iam
+	AwsSecretAccessKey string            `yaml:"aws-secret-access-key" secret:"true"`
+	AwsSessionToken    string            `yaml:"aws-session-token" secret:"true"`
+	AwsProfile         string            `yaml:"aws-profile"` // shared config profile of the default credential chain
+	AwsRegion          string            `yaml:"aws-region"`
+	AwsRoleArn         string            `yaml:"aws-role-arn"` // role assumed with the credentials above
+	AwsRoleSessionName string            `yaml:"aws-role-session-name"`
+	AwsExternalId      string            `yaml:"aws-external-id"`
+	AwsUserAgent       string            `yaml:"aws-user-agent"`
+	ClientId           string            `yaml:"client-id"` // oatuh
+	ClientSecret       string            `yaml:"client-secret" secret:"true"`
+	TokenEndpoint      string            `yaml:"token-endpoint"`
+	OidcDiscoveryUrl   string            `yaml:"oidc-discovery-url"` // issuer or .well-known url, instead of token-endpoint
+	Scopes             []string          `yaml:"scopes"`
+	Audience           string            `yaml:"audience"`
+	TokenParams        map[string]string `yaml:"token-params" secret:"true"` // extra token request parameters
+	KerberosConfig     string            `yaml:"kerberos-config-path"`       // kerberos, $KRB5_CONFIG or /etc/krb5.conf when empty
+	KeyTab             string            `yaml:"keytab-path"`
+	CCache             string            `yaml:"ccache-path"`        // ticket cache, e.g. kept fresh by kinit or k5start
+	DisablePAFXFAST    bool              `yaml:"disable-pa-fx-fast"` // for KDCs without FAST, e.g. Active Directory
+	Realm              string            `yaml:"realm"`
+	Servicename        string            `yaml:"servicename"` // kafka when empty
+}
+
+// TlsConfig is the TLS setting of a connection. The client certificate comes from
+// PEM files, inline PEM or a keystore, the CA from PEM files, inline PEM or a
+// truststore; without a CA the system roots are used.
+type TlsConfig struct {
+	Enabled      bool        `yaml:"enabled"` // TLS without any other setting
+	Certfile     string      `yaml:"certfile"`
+	Keyfile      string      `yaml:"keyfile"`
+	Cafile       string      `yaml:"cafile"`
+	Cert         string      `yaml:"cert"` // inline PEM
+	Key          string      `yaml:"key" secret:"true"`
+	Ca           string      `yaml:"ca"`
+	Keystore     StoreConfig `yaml:"keystore"`
+	Truststore   StoreConfig `yaml:"truststore"`
+	MinVersion   string      `yaml:"min-version"` // 1.0, 1.1, 1.2, 1.3
+	CipherSuites []string    `yaml:"cipher-suites"`
+	ServerName   string      `yaml:"server-name"` // SNI and verified host name
+	SkipVerify   bool        `yaml:"skipverify"`
+}
+
+// StoreConfig is a PKCS#12 or JKS key or trust store.
+type StoreConfig struct {
+	Path        string `yaml:"path"`
+	Type        string `yaml:"type"` // pkcs12, jks; from the file extension when empty
+	Password    string `yaml:"password" secret:"true"`
+	KeyPassword string `yaml:"key-password" secret:"true"` // jks key entry, the store password when empty
+}
+
+// IsSet reports whether TLS is used.
+func (t TlsConfig) IsSet() bool {
+	return !reflect.ValueOf(t).IsZero()
+}
+
+// CipherSuite returns the cipher suite crypto/tls knows by name, secure or
+// insecure, or nil. A TLS 1.3 suite is returned too, though crypto/tls always
+// picks the TLS 1.3 suites itself; see Tls13Only.
+func CipherSuite(name string) *tls.CipherSuite {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite
+		}
+	}
+	return nil
+}
+
+// Tls13Only reports whether suite is only used by TLS 1.3.
+func Tls13Only(suite *tls.CipherSuite) bool {
+	for _, version := range suite.SupportedVersions {
+		if version < tls.VersionTLS13 {
+			return false
+		}
+	}
+	return true
+}
+
+// StoreType returns the type of the store, guessed from the file extension when not set.
+func (s StoreConfig) StoreType() string {
+	if s.Type != "" {
+		return strings.ToLower(s.Type)
+	}
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".p12", ".pfx", ".pkcs12":
+		return "pkcs12"
+	case ".jks":
+		return "jks"
+	}
+	return ""
+}
+
+// OauthConfig is an OAuth 2.0 client credentials grant.
+type OauthConfig struct {
+	ClientId         string            `yaml:"client-id"`
+	ClientSecret     string            `yaml:"client-secret" secret:"true"`
+	TokenEndpoint    string            `yaml:"token-endpoint"`
+	OidcDiscoveryUrl string            `yaml:"oidc-discovery-url"` // issuer or .well-known url, instead of token-endpoint
+	Scopes           []string          `yaml:"scopes"`
+	Audience         string            `yaml:"audience"`
+	TokenParams      map[string]string `yaml:"token-params" secret:"true"` // extra token request parameters
+}
+
+// IsSet reports whether the grant is used.
+func (o OauthConfig) IsSet() bool {
+	return !reflect.ValueOf(o).IsZero()
 }
 
 type TopicConfig struct {
-	Name          string `yaml:"name"`
-	Partition     string `yaml:"partition"`
-	Replicafactor string `yaml:"replica-factor"`
+	Name          string            `yaml:"name"`
+	Partition     int               `yaml:"partition"`
+	Replicafactor int               `yaml:"replica-factor"`
+	Configs       map[string]string `yaml:"configs"`       // topic configs, e.g. retention.ms
+	IfExists      string            `yaml:"if-exists"`     // keep, alter, fail-on-diff, recreate
+	ReadyTimeout  Duration          `yaml:"ready-timeout"` // wait for leaders and in-sync replicas of a created topic
+
+	TruncateBeforeRun bool `yaml:"truncate-before-run"` // delete the records of an existing topic at startup
+	DeleteAfterRun    bool `yaml:"delete-after-run"`    // delete the topic once datagen stops
+	Expand            struct {
+		Partitions int      `yaml:"partitions"` // partition count after the expansion
+		After      Duration `yaml:"after"`      // time after startup
+	} `yaml:"expand"`
 }
 
 type DatagenConfig struct {
-	GoRoutine string `yaml:"go-routine"`
-	Jitter    string `yaml:"jitter"`
-	Proudce   struct {
-		Mode             string `yaml:"mode"`
-		Interval         string `yaml:"interval"`
-		RatePerSecond    string `yaml:"rate-per-second"`
-		DataRateLimitBPS string `yaml:"data-rate-limit-bps"`
+	GoRoutine      int     `yaml:"go-routine"`
+	Jitter         float64 `yaml:"jitter"`
+	Targets        string  `yaml:"targets"`         // comma separated names of clusters, every cluster when empty
+	SequenceHeader bool    `yaml:"sequence-header"` // tag every record with its worker and sequence number
+	Produce        struct {
+		Mode             string   `yaml:"mode"`
+		Interval         Duration `yaml:"interval"`
+		RatePerSecond    Rate     `yaml:"rate-per-second"`
+		DataRateLimitBPS ByteRate `yaml:"data-rate-limit-bps"`
 	} `yaml:"produce"`
 	Message struct {
-		Mode         string `yaml:"mode"`
-		QuickStart   string `yaml:"quickstart"`
-		MessageBytes string `yaml:"message-bytes"`
+		Mode         string           `yaml:"mode"`
+		QuickStart   string           `yaml:"quickstart"`
+		MessageBytes ByteSize         `yaml:"message-bytes"`
+		Replay       ReplayConfig     `yaml:"replay"`
+		Mirror       MirrorConfig     `yaml:"mirror"`
+		Relational   RelationalConfig `yaml:"relational"`
 	} `yaml:"message"`
+	Transaction struct {
+		Records           int64    `yaml:"records"`    // records per transaction
+		Duration          Duration `yaml:"duration"`   // time per transaction
+		Timeout           Duration `yaml:"timeout"`    // producer transaction timeout
+		AbortRate         float64  `yaml:"abort-rate"` // 0 ~ 1
+		Verify            bool     `yaml:"verify"`     // read_committed verification consumer
+		VerifyGracePeriod Duration `yaml:"verify-grace-period"`
+	} `yaml:"transaction"`
 }
 
-func InitConfig(configPath string) *ConfigConfig {
-	var config *ConfigConfig
-	filename, _ := filepath.Abs(configPath)
-	yamlFile, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
+// SinkConfig is where the workers write their records. Any sink other than
+// kafka is a dry run that never connects to a broker.
+type SinkConfig struct {
+	Type   string `yaml:"type"`   // kafka, stdout, file
+	Format string `yaml:"format"` // jsonl, pretty
+	Path   string `yaml:"path"`   // file the records are appended to
+}
+
+// DryRun reports whether records are written somewhere other than Kafka.
+func (s SinkConfig) DryRun() bool {
+	return s.Type != value.SINK_KAFKA
+}
+
+// ExportConfig is the dataset written by the export command.
+type ExportConfig struct {
+	Records     int64    `yaml:"records"`
+	Format      string   `yaml:"format"`        // jsonl, csv, avro, parquet
+	Path        string   `yaml:"path"`          // <topic.name>.<format> when empty
+	MaxFileSize ByteSize `yaml:"max-file-size"` // size after which a new file is started, 0 never
+	Schema      string   `yaml:"schema"`        // Avro schema file of the values, the message mode when empty
+}
+
+// ReplayConfig is the file replayed by the replay message mode.
+type ReplayConfig struct {
+	Path           string  `yaml:"path"`
+	Format         string  `yaml:"format"`          // jsonl, csv, avro, kcat
+	PreserveTiming bool    `yaml:"preserve-timing"` // wait between records as their timestamps did
+	Speed          float64 `yaml:"speed"`           // timing multiplier, 2 replays twice as fast
+	AtEOF          string  `yaml:"at-eof"`          // stop, loop
+	KeepTimestamps bool    `yaml:"keep-timestamps"` // produce the timestamps of the file instead of the produce time
+}
+
+// MirrorConfig is the topic the mirror message mode consumes and re-produces.
+type MirrorConfig struct {
+	Topic           string `yaml:"topic"`
+	Cluster         string `yaml:"cluster"`          // name of clusters to consume from, the first cluster or bootstrap-server when empty
+	Start           string `yaml:"start"`            // earliest, latest
+	Amplification   int    `yaml:"amplification"`    // records produced per consumed record
+	RandomizeKey    bool   `yaml:"randomize-key"`    // add a random suffix to every key
+	RandomizeFields string `yaml:"randomize-fields"` // comma separated JSON fields of the value, e.g. id,customer.id
+}
+
+// Fields returns the randomize-fields, each split into its nested field names.
+func (m MirrorConfig) Fields() [][]string {
+	var fields [][]string
+	for _, field := range splitList(m.RandomizeFields) {
+		fields = append(fields, strings.Split(field, "."))
+	}
+	return fields
+}
+
+// RelationalConfig is the mix of entities of the relational message mode.
+type RelationalConfig struct {
+	Ratios struct {
+		Customers  int `yaml:"customers"`
+		Products   int `yaml:"products"`
+		Orders     int `yaml:"orders"`
+		OrderItems int `yaml:"order-items"`
+		Payments   int `yaml:"payments"`
+	} `yaml:"ratios"` // share of the records of each entity
+	KeyPool int `yaml:"key-pool"` // recent parent keys the child records reference
+}
+
+// Ratio returns the ratio of entity.
+func (r RelationalConfig) Ratio(entity string) int {
+	switch entity {
+	case value.ENTITY_CUSTOMERS:
+		return r.Ratios.Customers
+	case value.ENTITY_PRODUCTS:
+		return r.Ratios.Products
+	case value.ENTITY_ORDERS:
+		return r.Ratios.Orders
+	case value.ENTITY_ORDER_ITEMS:
+		return r.Ratios.OrderItems
+	case value.ENTITY_PAYMENTS:
+		return r.Ratios.Payments
+	}
+	return 0
+}
+
+// ControlConfig enables the HTTP control API of a running generator.
+type ControlConfig struct {
+	Listen string `yaml:"listen"`              // e.g. :8080, empty disables the API
+	Token  string `yaml:"token" secret:"true"` // bearer token required by every request
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Cluster targets                          **
+**                                                                   **
+***********************************************************************/
+// DEFAULT_CLUSTER names the cluster of bootstrap-server.
+const DEFAULT_CLUSTER = "default"
+
+// Target is a cluster the run produces to, with its effective producer settings.
+type Target struct {
+	Name            string
+	BootstrapServer string
+	Producer        ProducerConfig
+}
+
+// Targets returns the clusters named by datagen.targets in the order of
+// clusters, every cluster when it is empty, or the bootstrap-server cluster
+// when no clusters are set.
+func (c *ConfigConfig) Targets() []Target {
+	if len(c.Clusters) == 0 {
+		return []Target{{Name: DEFAULT_CLUSTER, BootstrapServer: c.BootstrapServer, Producer: c.Producer}}
+	}
+	names := splitList(c.Datagen.Targets)
+	var targets []Target
+	for _, cluster := range c.Clusters {
+		if len(names) > 0 && !contains(names, cluster.Name) {
+			continue
+		}
+		targets = append(targets, c.target(cluster))
+	}
+	return targets
+}
+
+// Topics returns the topics datagen produces to: topic.name, or in the relational
+// message mode a topic per entity named <topic.name>.<entity> with the other
+// settings of topic.
+func (c *ConfigConfig) Topics() []TopicConfig {
+	if c.Datagen.Message.Mode != value.MESSAGE_MODE_RELATIONAL {
+		return []TopicConfig{c.Topic}
+	}
+	var topics []TopicConfig
+	for _, entity := range value.RELATIONAL_ENTITIES {
+		ct := c.Topic
+		ct.Name = c.Topic.Name + "." + entity
+		topics = append(topics, ct)
 	}
-	err = yaml.Unmarshal(yamlFile, &config)
+	return topics
+}
+
+// MirrorSource returns the cluster the mirror message mode consumes from.
+func (c *ConfigConfig) MirrorSource() Target {
+	if len(c.Clusters) == 0 {
+		return Target{Name: DEFAULT_CLUSTER, BootstrapServer: c.BootstrapServer, Producer: c.Producer}
+	}
+	name := c.Datagen.Message.Mirror.Cluster
+	for _, cluster := range c.Clusters {
+		if name == "" || cluster.Name == name {
+			return c.target(cluster)
+		}
+	}
+	return Target{Name: name}
+}
+
+// target returns cluster with the top-level producer settings it does not replace.
+func (c *ConfigConfig) target(cluster ClusterConfig) Target {
+	cp := c.Producer
+	if cluster.Producer.Sasl.Mechanism != "" {
+		cp.Sasl = cluster.Producer.Sasl
+	}
+	if cluster.Producer.Tls.IsSet() {
+		cp.Tls = cluster.Producer.Tls
+	}
+	return Target{Name: cluster.Name, BootstrapServer: cluster.BootstrapServer, Producer: cp}
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/**********************************************************************
+**                                                                   **
+**                              Defaults                             **
+**                                                                   **
+***********************************************************************/
+// defaultConfig holds every default value. The YAML file is decoded on top of it.
+func defaultConfig() *ConfigConfig {
+	config := &ConfigConfig{}
+	config.Topic.Partition = 3
+	config.Topic.Replicafactor = 1
+	config.Topic.IfExists = "keep"
+	config.Topic.ReadyTimeout = Duration(30 * time.Second)
+	config.Datagen.GoRoutine = 1
+	config.Datagen.Produce.Interval = Duration(100 * time.Millisecond)
+	config.Datagen.Produce.RatePerSecond = 100
+	config.Datagen.Produce.DataRateLimitBPS = 100
+	config.Datagen.Message.MessageBytes = 100
+	config.Datagen.Transaction.Timeout = Duration(5 * time.Second)
+	config.Datagen.Transaction.VerifyGracePeriod = Duration(10 * time.Second)
+	config.Producer.SchemaRegistry.Server.Timeout = Duration(5 * time.Second)
+	config.Sink.Type = "kafka"
+	config.Sink.Format = "jsonl"
+	config.Datagen.Message.Replay.Format = "jsonl"
+	config.Datagen.Message.Replay.Speed = 1
+	config.Datagen.Message.Replay.AtEOF = "stop"
+	config.Datagen.Message.Mirror.Start = "earliest"
+	config.Datagen.Message.Mirror.Amplification = 1
+	config.Datagen.Message.Relational.Ratios.Customers = 1
+	config.Datagen.Message.Relational.Ratios.Products = 1
+	config.Datagen.Message.Relational.Ratios.Orders = 4
+	config.Datagen.Message.Relational.Ratios.OrderItems = 10
+	config.Datagen.Message.Relational.Ratios.Payments = 3
+	config.Datagen.Message.Relational.KeyPool = 10000
+	config.Export.Records = 1000
+	config.Export.Format = "jsonl"
+	return config
+}
+
+// Load layers the config file, DATAGEN_ environment variables and command-line
+// overrides on top of the defaults, validates the result and reports every problem at once.
+// An empty configPath skips the file.
+func Load(configPath string, overrides Overrides) (*ConfigConfig, error) {
+	return load(configPath, overrides, false)
+}
+
+// LoadOffline is Load for commands that never connect to Kafka, such as export,
+// so no bootstrap-server is required.
+func LoadOffline(configPath string, overrides Overrides) (*ConfigConfig, error) {
+	return load(configPath, overrides, true)
+}
+
+func load(configPath string, overrides Overrides, offline bool) (*ConfigConfig, error) {
+	config := defaultConfig()
+	var errs Errors
+	if configPath != "" {
+		yamlFile, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		errs = decodeYAML(yamlFile, config)
+	}
+	config.file, config.overrides = configPath, overrides
+	config.sources = make(map[string]string)
+	errs = append(errs, applyEnv(config)...)
+	errs = append(errs, applyOverrides(config, overrides)...)
+	errs = append(errs, resolveRefs(config)...)
+	invalid := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		invalid[e.Path] = true
+	}
+	// a value that could not be decoded is reported once
+	for _, e := range validate(config, offline) {
+		if !invalid[e.Path] {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		errs.sort()
+		return nil, errs
+	}
+	return config, nil
+}
+
+func InitConfig(configPath string, overrides Overrides) *ConfigConfig {
+	filename := configPath
+	if filename != "" {
+		filename, _ = filepath.Abs(configPath)
+	}
+	config, err := Load(filename, overrides)
 	if err != nil {
-		logger.Log.Error(err.Error())
+		logger.Log.Error(fmt.Sprintln("invalid configuration file : ", filename))
+		logProblems(err)
 		os.Exit(1)
 		return nil
 	}
 	logger.Log.Info("Successfully loaded configuration file")
+	return config
+}
 
-	// read config
-	viper.SetConfigFile(filename)
-	readErr := viper.ReadInConfig() // Find and read the config file
-	if readErr != nil {             // Handle errors reading the config file
-		panic(fmt.Errorf("fatal error config file: %w", readErr))
-	}
+/**********************************************************************
+**                                                                   **
+**                          Watch config file                        **
+**                                                                   **
+***********************************************************************/
+// Watch reloads the config file, and the secret files it references, whenever
+// they change and passes every valid new config to onChange together with the
+// keys that changed. An invalid change is logged and the running config is kept.
+func Watch(current *ConfigConfig, onChange func(next *ConfigConfig, changed []string)) {
+	var mu sync.Mutex
+	var secrets *secretWatcher
+	reload := func(name string) {
+		// editors fire several events per save; apply them one at a time
+		mu.Lock()
+		defer mu.Unlock()
 
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Fprintln(os.Stderr, "Config file changed: ", e.Name)
-		file, err := osext.Executable()
+		next, err := Load(current.file, current.overrides)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed retrieving executable name:", err.Error())
-			fmt.Fprintln(os.Stderr, "Manual restart is needed")
+			logger.Log.Error(fmt.Sprintln("config changed but is invalid, keeping the running config : ", name))
+			logProblems(err)
 			return
 		}
-		err = syscall.Exec(file, os.Args, os.Environ())
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed restarting:", err.Error())
-			fmt.Fprintln(os.Stderr, "Manual restart is needed")
+		// a reload may reference other secret files
+		secrets.watch(next)
+		changed := Diff(current, next)
+		if len(changed) == 0 {
 			return
 		}
+		logger.Log.Info(fmt.Sprintln("config changed : ", strings.Join(changed, ", ")))
+		onChange(next, changed)
+		current = next
+	}
+	mu.Lock()
+	secrets = newSecretWatcher(reload)
+	secrets.watch(current)
+	mu.Unlock()
+	if current.file == "" {
+		return
+	}
 
+	// read config
+	viper.SetConfigFile(current.file)
+	readErr := viper.ReadInConfig() // Find and read the config file
+	if readErr != nil {             // Handle errors reading the config file
+		panic(fmt.Errorf("fatal error config file: %w", readErr))
+	}
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reload(e.Name)
 	})
 	viper.WatchConfig()
-	return config
+}
+
+func logProblems(err error) {
+	for _, problem := range strings.Split(err.Error(), "\n") {
+		logger.Log.Error(problem)
+	}
 }
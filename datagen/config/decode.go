@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           Config errors                           **
+**                                                                   **
+***********************************************************************/
+// FieldError reports a problem with a single config value.
+type FieldError struct {
+	Path string // YAML path, e.g. datagen.produce.interval
+	Line int    // 0 when the value did not come from the YAML file
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+// Errors collects every problem found in a config instead of stopping at the first.
+type Errors []*FieldError
+
+func (errs Errors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// sort orders problems by line; values not from the YAML file come last.
+func (errs Errors) sort() {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if (errs[i].Line == 0) != (errs[j].Line == 0) {
+			return errs[j].Line == 0
+		}
+		return errs[i].Line < errs[j].Line
+	})
+}
+
+func (errs *Errors) add(path string, line int, format string, args ...interface{}) {
+	*errs = append(*errs, &FieldError{Path: path, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// valueSetter is implemented by the human-friendly types in types.go.
+type valueSetter interface {
+	Set(string) error
+}
+
+/**********************************************************************
+**                                                                   **
+**                          YAML decoding                            **
+**                                                                   **
+***********************************************************************/
+// decodeYAML decodes the document onto config, keeping the current value of
+// every key that is missing or empty. The line of every key is kept for validation.
+func decodeYAML(data []byte, config *ConfigConfig) Errors {
+	var errs Errors
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		errs.add("(root)", 0, "%s", err)
+		return errs
+	}
+	if len(root.Content) == 0 {
+		return errs
+	}
+	config.lines = make(map[string]int)
+	decodeNode(root.Content[0], reflect.ValueOf(config).Elem(), "", config.lines, &errs)
+	return errs
+}
+
+func decodeNode(node *yaml.Node, v reflect.Value, path string, lines map[string]int, errs *Errors) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if path != "" {
+		lines[path] = node.Line
+	}
+	// empty value keeps the default
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	// human-friendly types
+	if setter, ok := v.Addr().Interface().(valueSetter); ok {
+		if node.Kind != yaml.ScalarNode {
+			errs.add(path, node.Line, "expected a single value")
+			return
+		}
+		if err := setter.Set(node.Value); err != nil {
+			errs.add(path, node.Line, "%s", err)
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			elem.Elem().Set(v.Elem())
+		}
+		decodeNode(node, elem.Elem(), path, lines, errs)
+		v.Set(elem)
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			errs.add(path, node.Line, "expected a mapping")
+			return
+		}
+		fields := yamlFields(v.Type())
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			childPath := joinPath(path, keyNode.Value)
+			index, ok := fields[keyNode.Value]
+			if !ok {
+				errs.add(childPath, keyNode.Line, "unknown key")
+				continue
+			}
+			decodeNode(valueNode, v.Field(index), childPath, lines, errs)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			errs.add(path, node.Line, "expected a mapping")
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			elem := reflect.New(v.Type().Elem()).Elem()
+			decodeNode(valueNode, elem, joinPath(path, keyNode.Value), lines, errs)
+			v.SetMapIndex(reflect.ValueOf(keyNode.Value), elem)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			errs.add(path, node.Line, "expected a list")
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			decodeNode(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i), lines, errs)
+		}
+		v.Set(slice)
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			errs.add(path, node.Line, "expected a single value")
+			return
+		}
+		if err := setScalar(v, node.Value); err != nil {
+			errs.add(path, node.Line, "%s", err)
+		}
+	}
+}
+
+// setScalar parses a plain string, bool or number into v.
+func setScalar(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// yamlFields maps yaml tag names to struct field indexes.
+func yamlFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+func yamlName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func joinPath(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
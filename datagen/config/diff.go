@@ -0,0 +1,45 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+/**********************************************************************
+**                                                                   **
+**                            Config diff                            **
+**                                                                   **
+***********************************************************************/
+// Diff returns the YAML path of every value that differs between prev and next.
+// Maps and lists are compared as a whole.
+func Diff(prev *ConfigConfig, next *ConfigConfig) []string {
+	var changed []string
+	diffValue(reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem(), "", &changed)
+	return changed
+}
+
+func diffValue(a reflect.Value, b reflect.Value, path string, changed *[]string) {
+	if a.Kind() == reflect.Struct {
+		for i := 0; i < a.NumField(); i++ {
+			if name := yamlName(a.Type().Field(i)); name != "" {
+				diffValue(a.Field(i), b.Field(i), joinPath(path, name), changed)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*changed = append(*changed, path)
+	}
+}
+
+// ChangedUnder reports whether any of the changed paths is prefix or lies below it.
+func ChangedUnder(changed []string, prefixes ...string) bool {
+	for _, path := range changed {
+		for _, prefix := range prefixes {
+			if path == prefix || strings.HasPrefix(path, prefix+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           Config keys                             **
+**                                                                   **
+***********************************************************************/
+// ENV_PREFIX prefixes the environment variable of every config key.
+const ENV_PREFIX = "DATAGEN_"
+
+// configKey is a single settable value of the config, e.g. topic.partition.
+type configKey struct {
+	path  string
+	usage string
+}
+
+// configKeys lists every single-value key of the config in YAML order.
+func configKeys() []configKey {
+	var keys []configKey
+	collectKeys(reflect.TypeOf(ConfigConfig{}), "", &keys)
+	return keys
+}
+
+func collectKeys(t reflect.Type, prefix string, keys *[]configKey) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		path := joinPath(prefix, name)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case reflect.PointerTo(ft).Implements(reflect.TypeOf((*valueSetter)(nil)).Elem()):
+			*keys = append(*keys, configKey{path: path, usage: ft.Name()})
+		case ft.Kind() == reflect.Struct:
+			collectKeys(ft, path, keys)
+		case ft.Kind() == reflect.Map || ft.Kind() == reflect.Slice:
+			// set through the YAML file only
+		default:
+			*keys = append(*keys, configKey{path: path, usage: ft.Kind().String()})
+		}
+	}
+}
+
+// envName returns the environment variable of a key without the prefix.
+// Levels are separated by "_" and "-" becomes "__", e.g. topic.replica-factor -> TOPIC_REPLICA__FACTOR.
+func envName(path string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(path, "-", "__"), ".", "_"))
+}
+
+/**********************************************************************
+**                                                                   **
+**                        Environment overrides                      **
+**                                                                   **
+***********************************************************************/
+// LEGACY_ENV_SECTIONS are the top-level keys the docker entrypoint took
+// unprefixed environment variables for (BOOTSTRAP*, PRODUCER*, TOPIC*, DATAGEN*).
+var LEGACY_ENV_SECTIONS = []string{"bootstrap-server", "producer", "topic", "datagen"}
+
+// applyEnv overrides keys from DATAGEN_-prefixed environment variables.
+// The unprefixed names of the legacy sections (BOOTSTRAP__SERVER, TOPIC_NAME,
+// DATAGEN_PRODUCE_MODE...) are still accepted; the prefixed name wins.
+func applyEnv(config *ConfigConfig) Errors {
+	var errs Errors
+	for _, key := range configKeys() {
+		name := envName(key.path)
+		envs := []string{ENV_PREFIX + name}
+		if section, _, _ := strings.Cut(key.path, "."); contains(LEGACY_ENV_SECTIONS, section) {
+			envs = append(envs, name)
+		}
+		for _, env := range envs {
+			s, ok := os.LookupEnv(env)
+			if !ok {
+				continue
+			}
+			if err := config.set(key.path, s); err != nil {
+				errs.add(key.path, 0, "environment variable %s: %s", env, err)
+			}
+			config.sources[key.path] = "env " + env
+			break
+		}
+	}
+	return errs
+}
+
+/**********************************************************************
+**                                                                   **
+**                        Command-line overrides                     **
+**                                                                   **
+***********************************************************************/
+// Overrides holds config values given as command-line flags, keyed by YAML path.
+type Overrides map[string]string
+
+// RegisterFlags adds one flag per config key, e.g. --topic.partition=6.
+func (o Overrides) RegisterFlags(fs *flag.FlagSet) {
+	for _, key := range configKeys() {
+		fs.Var(&overrideFlag{path: key.path, overrides: o}, key.path,
+			fmt.Sprintf("%s (env %s%s)", key.usage, ENV_PREFIX, envName(key.path)))
+	}
+}
+
+type overrideFlag struct {
+	path      string
+	overrides Overrides
+}
+
+func (f *overrideFlag) Set(s string) error {
+	f.overrides[f.path] = s
+	return nil
+}
+
+func (f *overrideFlag) String() string {
+	if f.overrides == nil {
+		return ""
+	}
+	return f.overrides[f.path]
+}
+
+// applyOverrides sets every command-line value on config.
+func applyOverrides(config *ConfigConfig, overrides Overrides) Errors {
+	var errs Errors
+	for _, key := range configKeys() {
+		s, ok := overrides[key.path]
+		if !ok {
+			continue
+		}
+		if err := config.set(key.path, s); err != nil {
+			errs.add(key.path, 0, "flag --%s: %s", key.path, err)
+		}
+		config.sources[key.path] = "flag --" + key.path
+	}
+	return errs
+}
+
+// set parses s into the key at path.
+func (c *ConfigConfig) set(path string, s string) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		index, ok := yamlFields(v.Type())[name]
+		if !ok {
+			return fmt.Errorf("unknown key %s", path)
+		}
+		v = v.Field(index)
+	}
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	return setValue(v, s)
+}
+
+func setValue(v reflect.Value, s string) error {
+	if setter, ok := v.Addr().Interface().(valueSetter); ok {
+		return setter.Set(s)
+	}
+	return setScalar(v, s)
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Runtime overrides                        **
+**                                                                   **
+***********************************************************************/
+// With returns a validated copy of the config with values set by YAML path.
+// source names where the values came from, e.g. "control api".
+func (c *ConfigConfig) With(values map[string]string, source string) (*ConfigConfig, error) {
+	next := *c
+	next.sources = make(map[string]string, len(c.sources)+len(values))
+	for path, s := range c.sources {
+		next.sources[path] = s
+	}
+
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs Errors
+	for _, path := range paths {
+		if err := next.set(path, values[path]); err != nil {
+			errs.add(path, 0, "%s", err)
+			continue
+		}
+		next.sources[path] = source
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if err := Validate(&next); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TEST_CONFIG is a valid config file for the load tests.
+const TEST_CONFIG = `bootstrap-server: localhost:9092
+topic:
+  name: datagen
+  partition: 3
+datagen:
+  produce:
+    mode: rate-per-second
+    rate-per-second: 10
+  message:
+    mode: quickstart
+    quickstart: user
+`
+
+// writeConfig writes a config file of TEST_CONFIG followed by extra.
+func writeConfig(t *testing.T, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "datagen.yaml")
+	if err := os.WriteFile(path, []byte(TEST_CONFIG+extra), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		flags      Overrides
+		want       int
+		wantSource string
+	}{
+		{name: "yaml", want: 3},
+		{name: "env over yaml", env: map[string]string{"DATAGEN_TOPIC_PARTITION": "6"}, want: 6, wantSource: "env DATAGEN_TOPIC_PARTITION"},
+		{name: "flag over env", env: map[string]string{"DATAGEN_TOPIC_PARTITION": "6"}, flags: Overrides{"topic.partition": "9"}, want: 9, wantSource: "flag --topic.partition"},
+		{name: "legacy env", env: map[string]string{"TOPIC_PARTITION": "4"}, want: 4, wantSource: "env TOPIC_PARTITION"},
+		{name: "prefixed over legacy env", env: map[string]string{"TOPIC_PARTITION": "4", "DATAGEN_TOPIC_PARTITION": "6"}, want: 6, wantSource: "env DATAGEN_TOPIC_PARTITION"},
+	}
+	path := writeConfig(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, s := range tt.env {
+				t.Setenv(name, s)
+			}
+			config, err := Load(path, tt.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Topic.Partition != tt.want {
+				t.Errorf("topic.partition = %d, want %d", config.Topic.Partition, tt.want)
+			}
+			if source := config.sources["topic.partition"]; source != tt.wantSource {
+				t.Errorf("source %q, want %q", source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadLegacyEnv(t *testing.T) {
+	// only the sections of the earlier docker image are read without the prefix
+	t.Setenv("SINK_TYPE", "stdout")
+	t.Setenv("EXPORT_RECORDS", "5")
+	t.Setenv("DATAGEN_MESSAGE_QUICKSTART", "book")
+	config, err := Load(writeConfig(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Sink.Type != "kafka" || config.Export.Records != 1000 {
+		t.Errorf("sink.type %q, export.records %d set by unprefixed variables", config.Sink.Type, config.Export.Records)
+	}
+	if config.Datagen.Message.QuickStart != "book" {
+		t.Errorf("datagen.message.quickstart = %q, want book", config.Datagen.Message.QuickStart)
+	}
+}
+
+func TestRedactedYAML(t *testing.T) {
+	t.Setenv("DATAGEN_PRODUCER_SCHEMA__REGISTRY_SERVER_PASSWORD", "env-secret")
+	path := writeConfig(t, `producer:
+  sasl:
+    mechanism: SCRAM-SHA-512
+    username: admin
+    password: yaml-secret
+  schema-registry:
+    server:
+      urls: http://localhost:8081
+      username: sr
+      headers:
+        X-Api-Key: header-secret
+    subject: datagen-value
+    type: avro
+control:
+  listen: localhost:0
+`)
+	config, err := Load(path, Overrides{"control.token": "flag-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := config.RedactedYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"yaml-secret", "env-secret", "header-secret", "flag-secret"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("print-config shows %s:\n%s", secret, out)
+		}
+	}
+	if n := strings.Count(string(out), REDACTED); n != 4 {
+		t.Errorf("%d redacted values, want 4:\n%s", n, out)
+	}
+	if !strings.Contains(string(out), "username: admin") {
+		t.Errorf("print-config hides username:\n%s", out)
+	}
+	// the loaded config keeps the values
+	if config.Producer.Sasl.Password != "yaml-secret" || config.Producer.SchemaRegistry.Server.Headers["X-Api-Key"] != "header-secret" {
+		t.Errorf("redaction changed the config")
+	}
+}
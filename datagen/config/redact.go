@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**********************************************************************
+**                                                                   **
+**                          Secret redaction                         **
+**                                                                   **
+***********************************************************************/
+const REDACTED = "******"
+
+// Redacted returns a copy of the config with every `secret:"true"` value, or
+// every value of a `secret:"true"` map, masked and every value resolved from ${env:...} or ${file:...} shown as its reference.
+func (c *ConfigConfig) Redacted() *ConfigConfig {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem(), "", c.refs)
+	return &redacted
+}
+
+// RedactedYAML renders the effective config with secrets masked.
+func (c *ConfigConfig) RedactedYAML() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func redact(v reflect.Value, path string, refs map[string]string) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		field := v.Field(i)
+		fieldPath := joinPath(path, name)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redact(field, fieldPath, refs)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct && !field.IsNil():
+			// the list is shared with the original config
+			copied := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(copied, field)
+			for j := 0; j < copied.Len(); j++ {
+				redact(copied.Index(j), fmt.Sprintf("%s[%d]", fieldPath, j), refs)
+			}
+			field.Set(copied)
+		case field.Kind() == reflect.Map && field.Type().Elem().Kind() == reflect.String && !field.IsNil():
+			// the map is shared with the original config
+			copied := reflect.MakeMap(field.Type())
+			for _, key := range field.MapKeys() {
+				elem := field.MapIndex(key)
+				if ref, ok := refs[joinPath(fieldPath, key.String())]; ok {
+					elem = reflect.ValueOf(ref).Convert(field.Type().Elem())
+				} else if f.Tag.Get("secret") == "true" && elem.String() != "" {
+					elem = reflect.ValueOf(REDACTED).Convert(field.Type().Elem())
+				}
+				copied.SetMapIndex(key, elem)
+			}
+			field.Set(copied)
+		case field.Kind() != reflect.String:
+		case refs[fieldPath] != "":
+			field.SetString(refs[fieldPath])
+		case f.Tag.Get("secret") == "true" && field.String() != "":
+			field.SetString(REDACTED)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"spitha/datagen/datagen/logger"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+/**********************************************************************
+**                                                                   **
+**                         Secret references                         **
+**                                                                   **
+***********************************************************************/
+// refPattern matches ${env:VAR} and ${file:/path} inside a string value.
+var refPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// resolveRefs replaces the references in every string value with the
+// environment variable or the file content. The reference is kept in refs so
+// the resolved value is never printed.
+func resolveRefs(config *ConfigConfig) Errors {
+	var errs Errors
+	config.refs = make(map[string]string)
+	resolveValue(reflect.ValueOf(config).Elem(), "", config, &errs)
+	return errs
+}
+
+func resolveValue(v reflect.Value, path string, config *ConfigConfig, errs *Errors) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if name := yamlName(v.Type().Field(i)); name != "" {
+				resolveValue(v.Field(i), joinPath(path, name), config, errs)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			resolveValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), config, errs)
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			resolveValue(elem, joinPath(path, key.String()), config, errs)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		ref := v.String()
+		if !refPattern.MatchString(ref) {
+			return
+		}
+		resolved, err := resolveString(ref)
+		if err != nil {
+			errs.add(path, config.line(path), "%s", err)
+			return
+		}
+		config.refs[path] = ref
+		v.SetString(resolved)
+	}
+}
+
+// resolveString resolves every reference in s. Errors never contain the resolved value.
+func resolveString(s string) (string, error) {
+	var err error
+	resolved := refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := refPattern.FindStringSubmatch(ref)
+		switch m[1] {
+		case "env":
+			v, ok := os.LookupEnv(m[2])
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", m[2])
+			}
+			return v
+		default: // file
+			data, readErr := os.ReadFile(m[2])
+			if readErr != nil && err == nil {
+				err = fmt.Errorf("cannot read secret file: %s", readErr)
+			}
+			// files written by editors and kubernetes secrets often end with a newline
+			return strings.TrimRight(string(data), "\r\n")
+		}
+	})
+	return resolved, err
+}
+
+// secretFiles lists the files referenced through ${file:...}.
+func (c *ConfigConfig) secretFiles() []string {
+	var files []string
+	for _, ref := range c.refs {
+		for _, m := range refPattern.FindAllStringSubmatch(ref, -1) {
+			if m[1] == "file" {
+				files = append(files, m[2])
+			}
+		}
+	}
+	return files
+}
+
+// secretWatcher calls reload when a referenced file changes. Directories are
+// watched since kubernetes replaces mounted secrets by swapping a symlink.
+type secretWatcher struct {
+	reload  func(name string)
+	watcher *fsnotify.Watcher // created once a file is referenced
+	dirs    map[string]bool
+}
+
+func newSecretWatcher(reload func(name string)) *secretWatcher {
+	return &secretWatcher{reload: reload, dirs: make(map[string]bool)}
+}
+
+// watch watches the directories of the files config references and stops
+// watching those it no longer does. It is called again on every reload.
+func (w *secretWatcher) watch(config *ConfigConfig) {
+	dirs := make(map[string]bool)
+	for _, file := range config.secretFiles() {
+		dirs[filepath.Dir(file)] = true
+	}
+	if len(dirs) == 0 && w.watcher == nil {
+		return
+	}
+	if w.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			logger.Log.Error(fmt.Sprintln("cannot watch secret files : ", err))
+			return
+		}
+		w.watcher = watcher
+		go w.run()
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			logger.Log.Error(fmt.Sprintln("cannot watch secret files : ", err))
+			continue
+		}
+		w.dirs[dir] = true
+	}
+}
+
+func (w *secretWatcher) run() {
+	for {
+		select {
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.reload(e.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Log.Error(fmt.Sprintln("secret file watch : ", err))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveString(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATAGEN_TEST_SECRET", "env-secret")
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${env:DATAGEN_TEST_SECRET}", want: "env-secret"},
+		{in: "${file:" + secretFile + "}", want: "file-secret"},
+		{in: "${env:DATAGEN_TEST_SECRET}:${file:" + secretFile + "}", want: "env-secret:file-secret"},
+		{in: "$DATAGEN_TEST_SECRET", want: "$DATAGEN_TEST_SECRET"},
+		{in: "${env:DATAGEN_TEST_UNSET}", wantErr: "environment variable DATAGEN_TEST_UNSET is not set"},
+		{in: "${file:" + filepath.Join(dir, "missing") + "}", wantErr: "cannot read secret file"},
+		{in: "${env:DATAGEN_TEST_SECRET}${env:DATAGEN_TEST_UNSET}", wantErr: "DATAGEN_TEST_UNSET"},
+	}
+	for _, tt := range tests {
+		got, err := resolveString(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveString(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "env-secret") {
+				t.Errorf("resolveString(%q) error contains the secret: %v", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveString(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api-key")
+	if err := os.WriteFile(keyFile, []byte("header-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATAGEN_TEST_PASSWORD", "env-secret")
+	path := writeConfig(t, `producer:
+  sasl:
+    mechanism: SCRAM-SHA-512
+    username: admin
+    password: ${env:DATAGEN_TEST_PASSWORD}
+  schema-registry:
+    server:
+      urls: http://localhost:8081
+      headers:
+        X-Api-Key: ${file:`+keyFile+`}
+    subject: datagen-value
+    type: avro
+`)
+	prev, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.Producer.Sasl.Password != "env-secret" || prev.Producer.SchemaRegistry.Server.Headers["X-Api-Key"] != "header-secret" {
+		t.Fatalf("references resolved to %q and %q", prev.Producer.Sasl.Password, prev.Producer.SchemaRegistry.Server.Headers["X-Api-Key"])
+	}
+	if files := prev.secretFiles(); len(files) != 1 || files[0] != keyFile {
+		t.Errorf("secret files %v, want %s", files, keyFile)
+	}
+
+	// print-config shows the references
+	out, err := prev.RedactedYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"password: ${env:DATAGEN_TEST_PASSWORD}", "X-Api-Key: ${file:" + keyFile + "}"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("print-config does not show %q:\n%s", want, out)
+		}
+	}
+
+	// a rotated secret is reported by key only
+	t.Setenv("DATAGEN_TEST_PASSWORD", "rotated-secret")
+	next, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := strings.Join(Diff(prev, next), ", ")
+	if changed != "producer.sasl.password" {
+		t.Errorf("changed %q, want producer.sasl.password", changed)
+	}
+
+	// an unset variable is reported with its line, without any value
+	os.Unsetenv("DATAGEN_TEST_PASSWORD")
+	_, err = Load(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "line 16: producer.sasl.password: environment variable DATAGEN_TEST_PASSWORD is not set") {
+		t.Errorf("error = %v, want the unset variable on line 16", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret") {
+		t.Errorf("error contains a secret: %v", err)
+	}
+}
+
+func TestSecretWatcher(t *testing.T) {
+	reloads := make(chan string, 16)
+	w := newSecretWatcher(func(name string) { reloads <- name })
+
+	w.watch(&ConfigConfig{}) // nothing referenced
+	if w.watcher != nil {
+		t.Fatalf("watching without secret files")
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	w.watch(&ConfigConfig{refs: map[string]string{"producer.sasl.password": "${file:" + filepath.Join(first, "password") + "}"}})
+	// a reload references a file in another directory
+	secondFile := filepath.Join(second, "password")
+	w.watch(&ConfigConfig{refs: map[string]string{"producer.sasl.password": "${file:" + secondFile + "}"}})
+	if len(w.dirs) != 1 || !w.dirs[second] {
+		t.Fatalf("watching %v, want only %s", w.dirs, second)
+	}
+
+	if err := os.WriteFile(filepath.Join(first, "password"), []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secondFile, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-reloads:
+		if name != secondFile {
+			t.Errorf("reload of %s, want %s", name, secondFile)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no reload after %s changed", secondFile)
+	}
+	w.watcher.Close()
+}
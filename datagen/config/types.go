@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**********************************************************************
+**                                                                   **
+**                        Human-friendly types                       **
+**                                                                   **
+***********************************************************************/
+// Every type below parses itself from a YAML scalar through Set and prints
+// itself back through String, so it can also be used as a flag.Value.
+
+// Duration accepts Go durations ("250ms", "10s", "1m30s").
+// A bare number is read as milliseconds.
+type Duration time.Duration
+
+func (d *Duration) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		if n < 0 {
+			return fmt.Errorf("duration must not be negative: %q", s)
+		}
+		if !inRange(n, float64(time.Millisecond)) {
+			return fmt.Errorf("invalid duration %q, expected e.g. 250ms, 10s or a number of milliseconds", s)
+		}
+		*d = Duration(n * float64(time.Millisecond))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected e.g. 250ms, 10s or a number of milliseconds", s)
+	}
+	if v < 0 {
+		return fmt.Errorf("duration must not be negative: %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) Duration() time.Duration { return time.Duration(d) }
+
+func (d Duration) MarshalYAML() (interface{}, error) { return d.String(), nil }
+
+// ByteSize accepts sizes with SI or IEC units ("100", "64KiB", "1MB").
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	// longest suffix first
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12},
+	{"k", 1e3}, {"m", 1e6}, {"g", 1e9}, {"t", 1e12},
+	{"b", 1},
+}
+
+func (b *ByteSize) Set(s string) error {
+	v, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(v)
+	return nil
+}
+
+func (b ByteSize) String() string { return formatBytes(int64(b)) }
+
+func (b ByteSize) Int() int { return int(b) }
+
+func (b ByteSize) MarshalYAML() (interface{}, error) { return b.String(), nil }
+
+// ByteRate accepts bytes per second with an optional "/s" or "ps" suffix ("50MB/s", "1MiBps").
+type ByteRate int64
+
+func (r *ByteRate) Set(s string) error {
+	trimmed := strings.TrimSpace(s)
+	lower := strings.ToLower(trimmed)
+	switch {
+	case strings.HasSuffix(lower, "/s"):
+		trimmed = trimmed[:len(trimmed)-2]
+	case strings.HasSuffix(lower, "ps"):
+		trimmed = trimmed[:len(trimmed)-2]
+	}
+	v, err := parseByteSize(trimmed)
+	if err != nil {
+		return fmt.Errorf("invalid byte rate %q, expected e.g. 50MB/s or a number of bytes per second", s)
+	}
+	*r = ByteRate(v)
+	return nil
+}
+
+func (r ByteRate) String() string { return formatBytes(int64(r)) + "/s" }
+
+func (r ByteRate) Int() int { return int(r) }
+
+func (r ByteRate) MarshalYAML() (interface{}, error) { return r.String(), nil }
+
+// Rate accepts records per second with an optional k/m multiplier and
+// "rps" or "/s" suffix ("500", "10k rps", "1.5m/s").
+type Rate int64
+
+func (r *Rate) Set(s string) error {
+	trimmed := strings.ToLower(strings.TrimSpace(s))
+	trimmed = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(trimmed, "rps"), "/s"))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(trimmed, "k"):
+		multiplier = 1e3
+	case strings.HasSuffix(trimmed, "m"):
+		multiplier = 1e6
+	}
+	if multiplier != 1 {
+		trimmed = strings.TrimSpace(trimmed[:len(trimmed)-1])
+	}
+	n, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || !inRange(n, multiplier) {
+		return fmt.Errorf("invalid rate %q, expected e.g. 500, 10k rps or 1.5m/s", s)
+	}
+	*r = Rate(math.Round(n * multiplier))
+	return nil
+}
+
+func (r Rate) String() string { return strconv.FormatInt(int64(r), 10) + " rps" }
+
+func (r Rate) Int() int { return int(r) }
+
+func (r Rate) MarshalYAML() (interface{}, error) { return r.String(), nil }
+
+/**********************************************************************
+**                                                                   **
+**                            Type utils                             **
+**                                                                   **
+***********************************************************************/
+func parseByteSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	lower := strings.ToLower(trimmed)
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			multiplier = unit.size
+			trimmed = strings.TrimSpace(trimmed[:len(trimmed)-len(unit.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || !inRange(n, float64(multiplier)) {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 100, 64KiB or 1MB", s)
+	}
+	return int64(math.Round(n * float64(multiplier))), nil
+}
+
+// inRange reports whether n times multiplier is a number that fits an int64
+// and is not negative. NaN and infinities are not.
+func inRange(n float64, multiplier float64) bool {
+	if math.IsNaN(n) || math.IsInf(n, 0) || n < 0 {
+		return false
+	}
+	return n*multiplier < math.MaxInt64
+}
+
+func formatBytes(n int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if n >= unit.size && n%unit.size == 0 {
+			return strconv.FormatInt(n/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
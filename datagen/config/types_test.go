@@ -0,0 +1,154 @@
+package config
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDurationSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "250ms", want: 250 * time.Millisecond},
+		{in: "1m30s", want: 90 * time.Second},
+		{in: "100", want: 100 * time.Millisecond},
+		{in: "1.5", want: 1500 * time.Microsecond},
+		{in: " 10s ", want: 10 * time.Second},
+		{in: "0", want: 0},
+		{in: "-1", wantErr: true},
+		{in: "-5s", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "-Inf", wantErr: true},
+		{in: "1e300", wantErr: true},
+		{in: "ten", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Duration
+		err := d.Set(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Duration.Set(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && d.Duration() != tt.want {
+			t.Errorf("Duration.Set(%q) = %s, want %s", tt.in, d.Duration(), tt.want)
+		}
+	}
+}
+
+func TestRateSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "500", want: 500},
+		{in: "10k rps", want: 10000},
+		{in: "1.5m/s", want: 1500000},
+		{in: "2K", want: 2000},
+		{in: "0", want: 0},
+		{in: "-1", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "nan rps", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "1e19", wantErr: true},
+		{in: "1e16m", wantErr: true},
+		{in: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		var r Rate
+		err := r.Set(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Rate.Set(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && r != tt.want {
+			t.Errorf("Rate.Set(%q) = %d, want %d", tt.in, r, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "100", want: 100},
+		{in: "64KiB", want: 64 << 10},
+		{in: "1MB", want: 1e6},
+		{in: "1.5k", want: 1500},
+		{in: "2 GiB", want: 2 << 30},
+		{in: "10b", want: 10},
+		{in: "0", want: 0},
+		{in: "-1", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "-Inf", wantErr: true},
+		{in: "9223372036854775807", wantErr: true},
+		{in: "1e7TiB", wantErr: true},
+		{in: "MB", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseByteSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestByteRateSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteRate
+		wantErr bool
+	}{
+		{in: "50MB/s", want: 50e6},
+		{in: "1MiBps", want: 1 << 20},
+		{in: "100", want: 100},
+		{in: "NaN/s", wantErr: true},
+		{in: "Infps", wantErr: true},
+	}
+	for _, tt := range tests {
+		var r ByteRate
+		err := r.Set(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ByteRate.Set(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && r != tt.want {
+			t.Errorf("ByteRate.Set(%q) = %d, want %d", tt.in, r, tt.want)
+		}
+	}
+}
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		n, multiplier float64
+		want          bool
+	}{
+		{n: 1, multiplier: 1, want: true},
+		{n: 0, multiplier: 1e6, want: true},
+		{n: -1, multiplier: 1, want: false},
+		{n: math.NaN(), multiplier: 1, want: false},
+		{n: math.Inf(1), multiplier: 1, want: false},
+		{n: math.Inf(-1), multiplier: 1, want: false},
+		{n: math.MaxInt64, multiplier: 1, want: false},
+		{n: 1e12, multiplier: 1e6, want: true},
+		{n: 1e13, multiplier: 1e6, want: false},
+	}
+	for _, tt := range tests {
+		if got := inRange(tt.n, tt.multiplier); got != tt.want {
+			t.Errorf("inRange(%v, %v) = %v, want %v", tt.n, tt.multiplier, got, tt.want)
+		}
+	}
+}
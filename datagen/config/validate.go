@@ -0,0 +1,688 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"strings"
+)
+
+/**********************************************************************
+**                                                                   **
+**                          Config validation                        **
+**                                                                   **
+***********************************************************************/
+// Validate checks a decoded config and reports every problem at once.
+func Validate(config *ConfigConfig) error {
+	if errs := validate(config, false); len(errs) > 0 {
+		errs.sort()
+		return errs
+	}
+	return nil
+}
+
+type validator struct {
+	config  *ConfigConfig
+	offline bool // the command never connects to Kafka
+	errs    Errors
+}
+
+func validate(config *ConfigConfig, offline bool) Errors {
+	v := &validator{config: config, offline: offline}
+	v.validateProducer()
+	v.validateClusters()
+	v.validateSasl()
+	v.validateTls()
+	v.validateSchemaRegistry()
+	v.validateTopic()
+	v.validateDatagen()
+	v.validateTransaction()
+	v.validateControl()
+	v.validateSink()
+	v.validateExport()
+	return v.errs
+}
+
+func (v *validator) validateProducer() {
+	cp := v.config.Producer
+	if len(v.config.Clusters) == 0 && !v.config.Sink.DryRun() && !v.offline {
+		v.required("bootstrap-server", v.config.BootstrapServer)
+	}
+	v.oneOf("producer.compression-type", cp.CompressionType, "uncompressed", "zstd", "lz4", "gzip", "snappy")
+	v.oneOf("producer.acks", cp.Acks, "0", "1", "all", "-1")
+	// unset leaves the client default
+	if cp.MaxMessageBytes > 1<<31-1 {
+		v.fail("producer.max-message-bytes", "must be at most 2GiB")
+	} else if cp.MaxMessageBytes < 0 || (cp.MaxMessageBytes == 0 && v.isSet("producer.max-message-bytes")) {
+		v.fail("producer.max-message-bytes", "must be positive")
+	}
+	if cp.RecordRetries != nil && *cp.RecordRetries < 0 {
+		v.fail("producer.record-retries", "must not be negative")
+	}
+	if cp.MaxInFlight < 0 {
+		v.fail("producer.max-in-flight", "must not be negative")
+	}
+
+	// acks and idempotence
+	acksAll := cp.Acks == "all" || cp.Acks == "-1" || (cp.Acks == "" && cp.TransactionalID != "")
+	if cp.TransactionalID != "" && cp.Acks != "" && !acksAll {
+		v.fail("producer.acks", "must be all with producer.transactional-id")
+	}
+	if cp.EnableIdempotence != nil {
+		switch {
+		case *cp.EnableIdempotence && !acksAll:
+			v.fail("producer.enable-idempotence", "requires producer.acks=all")
+		case !*cp.EnableIdempotence && cp.TransactionalID != "":
+			v.fail("producer.enable-idempotence", "cannot be disabled with producer.transactional-id")
+		}
+	}
+}
+
+func (v *validator) validateClusters() {
+	clusters := v.config.Clusters
+	if len(clusters) == 0 {
+		if v.isSet("datagen.targets") {
+			v.fail("datagen.targets", "requires clusters")
+		}
+		return
+	}
+	if v.config.BootstrapServer != "" {
+		v.fail("bootstrap-server", "cannot be used with clusters, set the bootstrap-server of every cluster")
+	}
+	names := make(map[string]bool, len(clusters))
+	for i, cluster := range clusters {
+		prefix := fmt.Sprintf("clusters[%d]", i)
+		if v.required(prefix+".name", cluster.Name) {
+			if names[cluster.Name] {
+				v.fail(prefix+".name", "duplicate cluster %q", cluster.Name)
+			}
+			names[cluster.Name] = true
+		}
+		v.required(prefix+".bootstrap-server", cluster.BootstrapServer)
+		v.validateSaslConfig(prefix+".producer.sasl", cluster.Producer.Sasl)
+		v.validateTlsConfig(prefix+".producer.tls", cluster.Producer.Tls)
+	}
+
+	// targets
+	seen := make(map[string]bool)
+	for _, name := range splitList(v.config.Datagen.Targets) {
+		switch {
+		case !names[name]:
+			v.fail("datagen.targets", "unknown cluster %q", name)
+		case seen[name]:
+			v.fail("datagen.targets", "duplicate cluster %q", name)
+		}
+		seen[name] = true
+	}
+}
+
+func (v *validator) validateSasl() {
+	v.validateSaslConfig("producer.sasl", v.config.Producer.Sasl)
+}
+
+// validateSaslConfig checks a SASL setting found at prefix.
+func (v *validator) validateSaslConfig(prefix string, sasl SaslConfig) {
+	mechanisms := []string{value.SASL_PLAIN, value.SASL_SCRAM_SHA_256, value.SASL_SCRAM_SHA_512, value.SASL_OAUTHBEARER, value.SASL_GSSAPI, value.SASL_AWS_MSK_IAM}
+	if !v.oneOf(prefix+".mechanism", sasl.Mechanism, mechanisms...) {
+		return
+	}
+
+	// mechanism specific fields
+	userPass := []string{value.SASL_PLAIN, value.SASL_SCRAM_SHA_256, value.SASL_SCRAM_SHA_512}
+	fields := []struct {
+		name       string
+		value      string
+		mechanisms []string // mechanisms that use the field
+		required   []string // mechanisms that require the field
+	}{
+		{"username", sasl.Username, append(userPass, value.SASL_GSSAPI), userPass},
+		{"password", sasl.Password, append(userPass, value.SASL_GSSAPI), userPass},
+		{"aws-access-key-id", sasl.AwsAccessKeyId, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-secret-access-key", sasl.AwsSecretAccessKey, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-session-token", sasl.AwsSessionToken, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-profile", sasl.AwsProfile, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-region", sasl.AwsRegion, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-role-arn", sasl.AwsRoleArn, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-role-session-name", sasl.AwsRoleSessionName, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-external-id", sasl.AwsExternalId, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"aws-user-agent", sasl.AwsUserAgent, []string{value.SASL_AWS_MSK_IAM}, nil},
+		{"client-id", sasl.ClientId, []string{value.SASL_OAUTHBEARER}, []string{value.SASL_OAUTHBEARER}},
+		{"client-secret", sasl.ClientSecret, []string{value.SASL_OAUTHBEARER}, []string{value.SASL_OAUTHBEARER}},
+		{"token-endpoint", sasl.TokenEndpoint, []string{value.SASL_OAUTHBEARER}, nil},
+		{"oidc-discovery-url", sasl.OidcDiscoveryUrl, []string{value.SASL_OAUTHBEARER}, nil},
+		{"audience", sasl.Audience, []string{value.SASL_OAUTHBEARER}, nil},
+		{"kerberos-config-path", sasl.KerberosConfig, []string{value.SASL_GSSAPI}, nil},
+		{"keytab-path", sasl.KeyTab, []string{value.SASL_GSSAPI}, nil},
+		{"ccache-path", sasl.CCache, []string{value.SASL_GSSAPI}, nil},
+		{"realm", sasl.Realm, []string{value.SASL_GSSAPI}, nil},
+		{"servicename", sasl.Servicename, []string{value.SASL_GSSAPI}, nil},
+	}
+	for _, f := range fields {
+		path := prefix + "." + f.name
+		switch {
+		case f.value == "" && contains(f.required, sasl.Mechanism):
+			v.fail(path, "is required with mechanism %s", sasl.Mechanism)
+		case f.value != "" && !contains(f.mechanisms, sasl.Mechanism):
+			v.fail(path, "is only used with mechanism %s", strings.Join(f.mechanisms, ", "))
+		}
+	}
+	// keys without a string value
+	for _, f := range []struct{ name, mechanism string }{
+		{"scopes", value.SASL_OAUTHBEARER},
+		{"token-params", value.SASL_OAUTHBEARER},
+		{"disable-pa-fx-fast", value.SASL_GSSAPI},
+	} {
+		if path := prefix + "." + f.name; v.isSet(path) && sasl.Mechanism != f.mechanism {
+			v.fail(path, "is only used with mechanism %s", f.mechanism)
+		}
+	}
+	if sasl.Mechanism == value.SASL_AWS_MSK_IAM {
+		// static keys, else the default credential chain
+		switch {
+		case (sasl.AwsAccessKeyId == "") != (sasl.AwsSecretAccessKey == ""):
+			v.fail(prefix+".aws-access-key-id", "and %s.aws-secret-access-key must be set together", prefix)
+		case sasl.AwsSessionToken != "" && sasl.AwsAccessKeyId == "":
+			v.fail(prefix+".aws-session-token", "requires %s.aws-access-key-id", prefix)
+		case sasl.AwsProfile != "" && sasl.AwsAccessKeyId != "":
+			v.fail(prefix+".aws-profile", "cannot be used with %s.aws-access-key-id", prefix)
+		}
+		if sasl.AwsRoleArn == "" {
+			for _, key := range []string{"aws-role-session-name", "aws-external-id"} {
+				if path := prefix + "." + key; v.isSet(path) {
+					v.fail(path, "requires %s.aws-role-arn", prefix)
+				}
+			}
+		}
+	}
+	if sasl.Mechanism == value.SASL_OAUTHBEARER {
+		switch {
+		case sasl.TokenEndpoint == "" && sasl.OidcDiscoveryUrl == "":
+			v.fail(prefix+".token-endpoint", "is required with mechanism %s unless %s.oidc-discovery-url is set", sasl.Mechanism, prefix)
+		case sasl.TokenEndpoint != "" && sasl.OidcDiscoveryUrl != "":
+			v.fail(prefix+".oidc-discovery-url", "cannot be used with %s.token-endpoint", prefix)
+		}
+	}
+	if sasl.Mechanism == value.SASL_GSSAPI {
+		// exactly one login: keytab, password or ticket cache
+		logins := 0
+		for _, login := range []string{sasl.KeyTab, sasl.Password, sasl.CCache} {
+			if login != "" {
+				logins++
+			}
+		}
+		switch {
+		case logins == 0:
+			v.fail(prefix, "one of keytab-path, password or ccache-path is required with mechanism %s", sasl.Mechanism)
+		case logins > 1:
+			v.fail(prefix, "only one of keytab-path, password or ccache-path can be used")
+		case sasl.CCache == "":
+			// the ticket cache holds the principal
+			v.required(prefix+".username", sasl.Username)
+			v.required(prefix+".realm", sasl.Realm)
+		}
+		v.fileExists(prefix+".kerberos-config-path", sasl.KerberosConfig)
+		v.fileExists(prefix+".keytab-path", sasl.KeyTab)
+		v.fileExists(prefix+".ccache-path", sasl.CCache)
+	}
+}
+
+func (v *validator) validateTls() {
+	v.validateTlsConfig("producer.tls", v.config.Producer.Tls)
+}
+
+// validateTlsConfig checks a TLS setting found at prefix.
+func (v *validator) validateTlsConfig(prefix string, tc TlsConfig) {
+	// pairs
+	pairs := []struct{ cert, key, certValue, keyValue string }{
+		{"certfile", "keyfile", tc.Certfile, tc.Keyfile},
+		{"cert", "key", tc.Cert, tc.Key},
+	}
+	for _, p := range pairs {
+		if (p.certValue == "") != (p.keyValue == "") {
+			if p.certValue == "" {
+				v.fail(prefix+"."+p.cert, "is required with %s.%s", prefix, p.key)
+			} else {
+				v.fail(prefix+"."+p.key, "is required with %s.%s", prefix, p.cert)
+			}
+		}
+	}
+
+	// a single source of the client certificate and of the CA
+	exclusive := []struct {
+		what    string
+		sources map[string]bool
+	}{
+		{"client certificate", map[string]bool{"certfile": tc.Certfile != "", "cert": tc.Cert != "", "keystore": tc.Keystore.Path != ""}},
+		{"CA", map[string]bool{"cafile": tc.Cafile != "", "ca": tc.Ca != "", "truststore": tc.Truststore.Path != ""}},
+	}
+	for _, e := range exclusive {
+		var set []string
+		for _, name := range []string{"certfile", "cert", "keystore", "cafile", "ca", "truststore"} {
+			if e.sources[name] {
+				set = append(set, prefix+"."+name)
+			}
+		}
+		if len(set) > 1 {
+			v.fail(set[1], "cannot be used with %s, set a single %s", set[0], e.what)
+		}
+	}
+
+	// stores
+	for _, store := range []struct {
+		name   string
+		config StoreConfig
+	}{{"keystore", tc.Keystore}, {"truststore", tc.Truststore}} {
+		path := prefix + "." + store.name
+		if store.config.Path == "" {
+			for _, key := range []string{"type", "password", "key-password"} {
+				if v.isSet(path + "." + key) {
+					v.fail(path+"."+key, "requires %s.path", path)
+				}
+			}
+			continue
+		}
+		if store.config.StoreType() == "" {
+			v.fail(path+".type", "is required when the file extension is not .p12, .pfx or .jks")
+		} else {
+			v.oneOf(path+".type", store.config.StoreType(), "pkcs12", "jks")
+		}
+		if store.name == "truststore" && store.config.KeyPassword != "" {
+			v.fail(path+".key-password", "is only used with %s.keystore", prefix)
+		}
+		v.fileExists(path+".path", store.config.Path)
+	}
+
+	v.oneOf(prefix+".min-version", tc.MinVersion, "1.0", "1.1", "1.2", "1.3")
+	for i, name := range tc.CipherSuites {
+		path := fmt.Sprintf("%s.cipher-suites[%d]", prefix, i)
+		switch suite := CipherSuite(name); {
+		case suite == nil:
+			v.fail(path, "unknown cipher suite %q", name)
+		case Tls13Only(suite):
+			v.fail(path, "%q is a TLS 1.3 cipher suite, which cannot be configured", name)
+		}
+	}
+	if tc.MinVersion == "1.3" && len(tc.CipherSuites) > 0 {
+		v.fail(prefix+".cipher-suites", "cannot be used with min-version 1.3, whose cipher suites cannot be configured")
+	}
+	v.fileExists(prefix+".certfile", tc.Certfile)
+	v.fileExists(prefix+".keyfile", tc.Keyfile)
+	v.fileExists(prefix+".cafile", tc.Cafile)
+}
+
+func (v *validator) validateSchemaRegistry() {
+	sr := v.config.Producer.SchemaRegistry
+	if sr.Server.Urls == "" {
+		for _, path := range []string{
+			"producer.schema-registry.subject", "producer.schema-registry.type",
+			"producer.schema-registry.schema-id", "producer.schema-registry.version",
+			"producer.schema-registry.server.username", "producer.schema-registry.server.token",
+			"producer.schema-registry.server.oauth", "producer.schema-registry.server.headers",
+			"producer.schema-registry.server.tls",
+		} {
+			if v.isSet(path) {
+				v.fail(path, "requires producer.schema-registry.server.urls")
+			}
+		}
+		return
+	}
+	if v.required("producer.schema-registry.type", sr.Type) {
+		v.oneOf("producer.schema-registry.type", sr.Type, value.SCHEMA_REGISTRY_MEESAGE_TYPE_AVRO, value.SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF, value.SCHEMA_REGISTRY_MEESAGE_TYPE_JSON)
+	}
+
+	// the schema: registered from the message mode, or pre-registered by id or subject version
+	switch {
+	case sr.SchemaId < 0:
+		v.fail("producer.schema-registry.schema-id", "must be positive")
+	case sr.SchemaId > 0:
+		for _, path := range []string{"producer.schema-registry.subject", "producer.schema-registry.version"} {
+			if v.isSet(path) {
+				v.fail(path, "cannot be used with producer.schema-registry.schema-id")
+			}
+		}
+	default:
+		v.required("producer.schema-registry.subject", sr.Subject)
+		if sr.Version != "" && sr.Version != value.SCHEMA_REGISTRY_VERSION_LATEST {
+			if version, err := strconv.Atoi(sr.Version); err != nil || version < 1 {
+				v.fail("producer.schema-registry.version", "invalid value %q, expected a positive number or %s", sr.Version, value.SCHEMA_REGISTRY_VERSION_LATEST)
+			}
+		}
+	}
+	if (sr.Server.Username == "") != (sr.Server.Password == "") {
+		v.fail("producer.schema-registry.server", "username and password must be set together")
+	}
+
+	// a single authentication: basic, bearer token or oauth
+	var auths []string
+	for _, auth := range []struct {
+		path string
+		set  bool
+	}{
+		{"producer.schema-registry.server.username", sr.Server.Username != ""},
+		{"producer.schema-registry.server.token", sr.Server.Token != ""},
+		{"producer.schema-registry.server.oauth", sr.Server.Oauth.IsSet()},
+	} {
+		if auth.set {
+			auths = append(auths, auth.path)
+		}
+	}
+	if len(auths) > 1 {
+		v.fail(auths[1], "cannot be used with %s", auths[0])
+	}
+	if sr.Server.Oauth.IsSet() {
+		v.validateOauth("producer.schema-registry.server.oauth", sr.Server.Oauth)
+	}
+	for name := range sr.Server.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			v.fail("producer.schema-registry.server.headers", "cannot set Authorization, use username, token or oauth")
+		}
+	}
+	if sr.Server.Timeout <= 0 {
+		v.fail("producer.schema-registry.server.timeout", "must be positive")
+	}
+	v.validateTlsConfig("producer.schema-registry.server.tls", sr.Server.Tls)
+}
+
+// validateOauth checks a client credentials grant found at prefix.
+func (v *validator) validateOauth(prefix string, o OauthConfig) {
+	v.required(prefix+".client-id", o.ClientId)
+	v.required(prefix+".client-secret", o.ClientSecret)
+	switch {
+	case o.TokenEndpoint == "" && o.OidcDiscoveryUrl == "":
+		v.fail(prefix+".token-endpoint", "is required unless %s.oidc-discovery-url is set", prefix)
+	case o.TokenEndpoint != "" && o.OidcDiscoveryUrl != "":
+		v.fail(prefix+".oidc-discovery-url", "cannot be used with %s.token-endpoint", prefix)
+	}
+}
+
+func (v *validator) validateTopic() {
+	ct := v.config.Topic
+	v.required("topic.name", ct.Name)
+	if ct.Partition < 1 {
+		v.fail("topic.partition", "must be at least 1")
+	}
+	if ct.Replicafactor < 1 || ct.Replicafactor > 1<<15-1 {
+		v.fail("topic.replica-factor", "must be between 1 and 32767")
+	}
+	if ct.ReadyTimeout <= 0 {
+		v.fail("topic.ready-timeout", "must be positive")
+	}
+	v.oneOf("topic.if-exists", ct.IfExists, value.TOPIC_IF_EXISTS_KEEP, value.TOPIC_IF_EXISTS_ALTER, value.TOPIC_IF_EXISTS_FAIL_ON_DIFF, value.TOPIC_IF_EXISTS_RECREATE)
+	for name := range ct.Configs {
+		if strings.TrimSpace(name) == "" {
+			v.fail("topic.configs", "config names must not be empty")
+		}
+	}
+	if ct.TruncateBeforeRun && ct.IfExists == value.TOPIC_IF_EXISTS_RECREATE {
+		v.fail("topic.truncate-before-run", "cannot be used with topic.if-exists recreate, which starts empty")
+	}
+	switch {
+	case ct.Expand.Partitions == 0:
+		if v.isSet("topic.expand.after") {
+			v.fail("topic.expand.after", "requires topic.expand.partitions")
+		}
+	case ct.Expand.Partitions <= ct.Partition:
+		v.fail("topic.expand.partitions", "must be greater than topic.partition (%d)", ct.Partition)
+	case ct.Expand.After <= 0:
+		v.fail("topic.expand.after", "is required with topic.expand.partitions")
+	}
+}
+
+func (v *validator) validateDatagen() {
+	dc := v.config.Datagen
+	if dc.GoRoutine < 1 {
+		v.fail("datagen.go-routine", "must be at least 1")
+	}
+	if dc.Jitter < 0 || dc.Jitter > 1 {
+		v.fail("datagen.jitter", "must be between 0 and 1")
+	}
+
+	// produce mode, unused by an offline command
+	if !v.offline && v.required("datagen.produce.mode", dc.Produce.Mode) &&
+		v.oneOf("datagen.produce.mode", dc.Produce.Mode, value.PRODUCE_MODE_INTERVAL, value.PRODUCE_MODE_RATE_PER_SEC, value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS) {
+		switch dc.Produce.Mode {
+		case value.PRODUCE_MODE_INTERVAL:
+			if dc.Produce.Interval <= 0 {
+				v.fail("datagen.produce.interval", "must be positive")
+			}
+		case value.PRODUCE_MODE_RATE_PER_SEC:
+			if dc.Produce.RatePerSecond < 1 {
+				v.fail("datagen.produce.rate-per-second", "must be at least 1")
+			}
+		case value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS:
+			if dc.Produce.DataRateLimitBPS < 1 {
+				v.fail("datagen.produce.data-rate-limit-bps", "must be at least 1 byte per second")
+			}
+		}
+	}
+
+	// message mode, unused by export with a schema
+	if v.offline && v.config.Export.Schema != "" {
+		return
+	}
+	if v.required("datagen.message.mode", dc.Message.Mode) &&
+		v.oneOf("datagen.message.mode", dc.Message.Mode, value.MESSAGE_MODE_QUICKSTART, value.MESSAGE_MODE_MESSAGE_BYTES, value.MESSAGE_MODE_REPLAY, value.MESSAGE_MODE_MIRROR, value.MESSAGE_MODE_RELATIONAL) {
+		switch dc.Message.Mode {
+		case value.MESSAGE_MODE_QUICKSTART:
+			if v.required("datagen.message.quickstart", dc.Message.QuickStart) {
+				v.oneOf("datagen.message.quickstart", dc.Message.QuickStart, value.QUICKSTART_USER, value.QUICKSTART_BOOK, value.QUICKSTART_CAR, value.QUICKSTART_ADDRESS, value.QUICKSTART_CONTACT, value.QUICKSTART_MOVIE, value.QUICKSTART_JOB)
+			}
+		case value.MESSAGE_MODE_MESSAGE_BYTES:
+			if dc.Message.MessageBytes < 1 {
+				v.fail("datagen.message.message-bytes", "must be at least 1 byte")
+			}
+		case value.MESSAGE_MODE_REPLAY:
+			v.validateReplay()
+		case value.MESSAGE_MODE_MIRROR:
+			v.validateMirror()
+		case value.MESSAGE_MODE_RELATIONAL:
+			v.validateRelational()
+		}
+	}
+}
+
+func (v *validator) validateReplay() {
+	cr := v.config.Datagen.Message.Replay
+	if v.required("datagen.message.replay.path", cr.Path) {
+		v.fileExists("datagen.message.replay.path", cr.Path)
+	}
+	v.oneOf("datagen.message.replay.format", cr.Format, value.REPLAY_FORMAT_JSONL, value.REPLAY_FORMAT_CSV, value.REPLAY_FORMAT_AVRO, value.REPLAY_FORMAT_KCAT)
+	if cr.Speed <= 0 {
+		v.fail("datagen.message.replay.speed", "must be greater than 0")
+	}
+	v.oneOf("datagen.message.replay.at-eof", cr.AtEOF, value.REPLAY_AT_EOF_STOP, value.REPLAY_AT_EOF_LOOP)
+	if v.config.Producer.SchemaRegistry.Server.Urls != "" {
+		v.fail("producer.schema-registry.server.urls", "cannot be used with datagen.message.mode replay, which produces the values as they are")
+	}
+}
+
+func (v *validator) validateMirror() {
+	cm := v.config.Datagen.Message.Mirror
+	v.required("datagen.message.mirror.topic", cm.Topic)
+	v.oneOf("datagen.message.mirror.start", cm.Start, value.MIRROR_START_EARLIEST, value.MIRROR_START_LATEST)
+	if cm.Amplification < 1 {
+		v.fail("datagen.message.mirror.amplification", "must be at least 1")
+	}
+	for _, field := range splitList(cm.RandomizeFields) {
+		if strings.Contains(field, "..") || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") {
+			v.fail("datagen.message.mirror.randomize-fields", "invalid field %q", field)
+		}
+	}
+	if v.config.Producer.SchemaRegistry.Server.Urls != "" {
+		v.fail("producer.schema-registry.server.urls", "cannot be used with datagen.message.mode mirror, which produces the values as they are")
+	}
+
+	// source cluster
+	source := v.config.MirrorSource()
+	switch {
+	case cm.Cluster != "" && len(v.config.Clusters) == 0:
+		v.fail("datagen.message.mirror.cluster", "requires clusters")
+		return
+	case cm.Cluster != "" && source.BootstrapServer == "":
+		v.fail("datagen.message.mirror.cluster", "unknown cluster %q", cm.Cluster)
+		return
+	case len(v.config.Clusters) == 0 && v.config.BootstrapServer == "" && (v.config.Sink.DryRun() || v.offline):
+		v.fail("bootstrap-server", "is required to consume datagen.message.mirror.topic")
+		return
+	}
+	if cm.Topic != v.config.Topic.Name || v.config.Sink.DryRun() {
+		return
+	}
+	for _, target := range v.config.Targets() {
+		if target.Name == source.Name {
+			v.fail("datagen.message.mirror.topic", "is topic.name on cluster %s, datagen would consume its own records", source.Name)
+		}
+	}
+}
+
+func (v *validator) validateRelational() {
+	cr := v.config.Datagen.Message.Relational
+	total := 0
+	for _, entity := range value.RELATIONAL_ENTITIES {
+		ratio := cr.Ratio(entity)
+		if ratio < 0 {
+			v.fail("datagen.message.relational.ratios."+strings.ReplaceAll(entity, "_", "-"), "must not be negative")
+		}
+		total += ratio
+	}
+	if total <= 0 {
+		v.fail("datagen.message.relational.ratios", "at least one entity needs a ratio above 0")
+	}
+	if cr.KeyPool < 1 {
+		v.fail("datagen.message.relational.key-pool", "must be at least 1")
+	}
+	if v.config.Producer.SchemaRegistry.Server.Urls != "" {
+		v.fail("producer.schema-registry.server.urls", "cannot be used with datagen.message.mode relational, which produces a JSON value per entity")
+	}
+	if v.config.Datagen.Transaction.Verify {
+		v.fail("datagen.transaction.verify", "cannot be used with datagen.message.mode relational, which produces to a topic per entity")
+	}
+}
+
+func (v *validator) validateTransaction() {
+	dt := v.config.Datagen.Transaction
+	if v.config.Producer.TransactionalID == "" {
+		for _, key := range []string{"records", "duration", "timeout", "abort-rate", "verify", "verify-grace-period"} {
+			if path := "datagen.transaction." + key; v.isSet(path) {
+				v.fail(path, "requires producer.transactional-id")
+			}
+		}
+		return
+	}
+	if dt.Records < 0 {
+		v.fail("datagen.transaction.records", "must not be negative")
+	}
+	if dt.AbortRate < 0 || dt.AbortRate > 1 {
+		v.fail("datagen.transaction.abort-rate", "must be between 0 and 1")
+	}
+	if dt.Timeout <= 0 {
+		v.fail("datagen.transaction.timeout", "must be greater than 0")
+	}
+	// a transaction spans every cluster, but reports a single outcome to the verifier
+	if dt.Verify && len(v.config.Targets()) > 1 {
+		v.fail("datagen.transaction.verify", "cannot be used with more than one cluster, select one with datagen.targets")
+	}
+}
+
+func (v *validator) validateControl() {
+	cc := v.config.Control
+	if cc.Token != "" && cc.Listen == "" {
+		v.fail("control.token", "requires control.listen")
+	}
+}
+
+func (v *validator) validateSink() {
+	cs := v.config.Sink
+	v.oneOf("sink.type", cs.Type, value.SINK_KAFKA, value.SINK_STDOUT, value.SINK_FILE)
+	v.oneOf("sink.format", cs.Format, value.SINK_FORMAT_JSONL, value.SINK_FORMAT_PRETTY)
+	switch {
+	case cs.Type == value.SINK_FILE:
+		v.required("sink.path", cs.Path)
+	case cs.Path != "":
+		v.fail("sink.path", "requires sink.type file")
+	}
+}
+
+func (v *validator) validateExport() {
+	ce := v.config.Export
+	if ce.Records < 1 {
+		v.fail("export.records", "must be at least 1")
+	}
+	v.oneOf("export.format", ce.Format, value.EXPORT_FORMAT_JSONL, value.EXPORT_FORMAT_CSV, value.EXPORT_FORMAT_AVRO, value.EXPORT_FORMAT_PARQUET)
+	if ce.MaxFileSize < 0 {
+		v.fail("export.max-file-size", "must not be negative")
+	}
+	v.fileExists("export.schema", ce.Schema)
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Validation utils                         **
+**                                                                   **
+***********************************************************************/
+func (v *validator) fail(path string, format string, args ...interface{}) {
+	if source, ok := v.config.sources[path]; ok {
+		v.errs.add(path, 0, format+" (set by %s)", append(args, source)...)
+		return
+	}
+	v.errs.add(path, v.config.line(path), format, args...)
+}
+
+// required reports whether s is set and records a problem otherwise.
+func (v *validator) required(path string, s string) bool {
+	if s == "" {
+		v.fail(path, "is required")
+		return false
+	}
+	return true
+}
+
+// oneOf reports whether s is empty or one of allowed and records a problem otherwise.
+func (v *validator) oneOf(path string, s string, allowed ...string) bool {
+	if s == "" || contains(allowed, s) {
+		return true
+	}
+	v.fail(path, "invalid value %q, expected one of %s", s, strings.Join(allowed, ", "))
+	return false
+}
+
+func (v *validator) fileExists(path string, file string) {
+	if file == "" {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		v.fail(path, "cannot read file: %s", err)
+	}
+}
+
+// isSet reports whether the key is present in the YAML file or overridden.
+func (v *validator) isSet(path string) bool {
+	_, inFile := v.config.lines[path]
+	_, overridden := v.config.sources[path]
+	return inFile || overridden
+}
+
+// line returns the line of path or of its closest parent in the YAML file.
+func (c *ConfigConfig) line(path string) int {
+	for path != "" {
+		if line, ok := c.lines[path]; ok {
+			return line
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
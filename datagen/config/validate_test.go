@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	// a file for the settings that must point to one
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the lines of extra start after the 11 lines of TEST_CONFIG
+	tests := []struct {
+		name  string
+		extra string
+		flags Overrides
+		want  []string
+	}{
+		{name: "valid"},
+		{
+			name: "valid sasl and tls",
+			extra: `producer:
+  sasl:
+    mechanism: SCRAM-SHA-512
+    username: user
+    password: secret
+  tls:
+    enabled: true
+    cafile: ` + file + `
+`,
+		},
+
+		// enums
+		{
+			name: "sasl mechanism",
+			extra: `producer:
+  sasl:
+    mechanism: KERBEROS
+`,
+			want: []string{`line 14: producer.sasl.mechanism: invalid value "KERBEROS", expected one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER, GSSAPI, AWS_MSK_IAM`},
+		},
+		{
+			name: "compression type and tls min version",
+			extra: `producer:
+  compression-type: brotli
+  tls:
+    min-version: "1.4"
+`,
+			want: []string{
+				`line 13: producer.compression-type: invalid value "brotli", expected one of uncompressed, zstd, lz4, gzip, snappy`,
+				`line 15: producer.tls.min-version: invalid value "1.4", expected one of 1.0, 1.1, 1.2, 1.3`,
+			},
+		},
+
+		// sasl
+		{
+			name: "sasl field of another mechanism",
+			extra: `producer:
+  sasl:
+    mechanism: PLAIN
+    username: user
+    password: secret
+    client-id: datagen
+`,
+			want: []string{`line 17: producer.sasl.client-id: is only used with mechanism OAUTHBEARER`},
+		},
+		{
+			name: "sasl required fields",
+			extra: `producer:
+  sasl:
+    mechanism: SCRAM-SHA-256
+`,
+			want: []string{
+				`line 14: producer.sasl.username: is required with mechanism SCRAM-SHA-256`,
+				`line 14: producer.sasl.password: is required with mechanism SCRAM-SHA-256`,
+			},
+		},
+		{
+			name: "oauthbearer token endpoint and discovery url",
+			extra: `producer:
+  sasl:
+    mechanism: OAUTHBEARER
+    client-id: datagen
+    client-secret: secret
+    token-endpoint: https://idp/token
+    oidc-discovery-url: https://idp/.well-known/openid-configuration
+`,
+			want: []string{`line 18: producer.sasl.oidc-discovery-url: cannot be used with producer.sasl.token-endpoint`},
+		},
+		{
+			name: "aws profile and static keys",
+			extra: `producer:
+  sasl:
+    mechanism: AWS_MSK_IAM
+    aws-access-key-id: AKIA
+    aws-secret-access-key: secret
+    aws-profile: dev
+`,
+			want: []string{`line 17: producer.sasl.aws-profile: cannot be used with producer.sasl.aws-access-key-id`},
+		},
+		{
+			name: "gssapi logins",
+			extra: `producer:
+  sasl:
+    mechanism: GSSAPI
+    username: datagen
+    realm: EXAMPLE.COM
+    password: secret
+    ccache-path: ` + file + `
+`,
+			want: []string{`line 14: producer.sasl: only one of keytab-path, password or ccache-path can be used`},
+		},
+
+		// tls
+		{
+			name: "tls ca from a file and inline",
+			extra: `producer:
+  tls:
+    enabled: true
+    cafile: ` + file + `
+    ca: |
+      -----BEGIN CERTIFICATE-----
+`,
+			want: []string{`line 16: producer.tls.ca: cannot be used with producer.tls.cafile, set a single CA`},
+		},
+		{
+			name: "tls client certificate pair",
+			extra: `producer:
+  tls:
+    enabled: true
+    cert: |
+      -----BEGIN CERTIFICATE-----
+`,
+			want: []string{`line 14: producer.tls.key: is required with producer.tls.cert`},
+		},
+		{
+			name: "tls file not found",
+			extra: `producer:
+  tls:
+    enabled: true
+    cafile: ` + file + `.missing
+`,
+			want: []string{`line 15: producer.tls.cafile: cannot read file`},
+		},
+
+		// lines
+		{
+			name: "problems in line order, overrides last",
+			extra: `producer:
+  acks: "1"
+  transactional-id: datagen
+  compression-type: brotli
+`,
+			flags: Overrides{"producer.sasl.mechanism": "KERBEROS"},
+			want: []string{
+				`line 13: producer.acks: must be all with producer.transactional-id`,
+				`line 15: producer.compression-type: invalid value "brotli"`,
+				`producer.sasl.mechanism: invalid value "KERBEROS", expected one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER, GSSAPI, AWS_MSK_IAM (set by flag --producer.sasl.mechanism)`,
+			},
+		},
+		{
+			name:  "problem of a missing key at its parent",
+			extra: "producer:\n  sasl:\n    mechanism: OAUTHBEARER\n",
+			want: []string{
+				`line 14: producer.sasl.client-id: is required with mechanism OAUTHBEARER`,
+				`line 14: producer.sasl.client-secret: is required with mechanism OAUTHBEARER`,
+				`line 14: producer.sasl.token-endpoint: is required with mechanism OAUTHBEARER unless producer.sasl.oidc-discovery-url is set`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.extra), tt.flags)
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d problems, want %d:\n%s", len(got), len(tt.want), strings.Join(got, "\n"))
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("problem %d:\n got %s\nwant %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
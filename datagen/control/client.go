@@ -0,0 +1,146 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/**********************************************************************
+**                                                                   **
+**                          Control client                           **
+**                                                                   **
+***********************************************************************/
+// Client calls the control API of a running generator.
+type Client struct {
+	Addr  string // e.g. http://localhost:8080
+	Token string
+	HTTP  *http.Client
+}
+
+func NewClient(addr string, token string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{Addr: strings.TrimSuffix(addr, "/"), Token: token, HTTP: &http.Client{Timeout: time.Minute}}
+}
+
+// Run runs a command line such as "pause 2" or "set rate=10k jitter=0.2".
+func (c *Client) Run(command string, args []string) (*Status, error) {
+	switch command {
+	case "status":
+		return c.call(http.MethodGet, "/status", nil)
+	case "pause", "resume":
+		switch len(args) {
+		case 0:
+			return c.call(http.MethodPost, "/"+command, nil)
+		case 1:
+			return c.call(http.MethodPost, "/workers/"+args[0]+"/"+command, nil)
+		}
+		return nil, fmt.Errorf("usage: %s [worker]", command)
+	case "set":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("usage: set <key>=<value>...")
+		}
+		values := make(map[string]string, len(args))
+		for _, arg := range args {
+			key, v, ok := strings.Cut(arg, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid setting %q, expected <key>=<value>", arg)
+			}
+			values[key] = v
+		}
+		return c.call(http.MethodPost, "/settings", values)
+	case "burst":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("usage: burst <factor> <duration>")
+		}
+		var factor float64
+		if _, err := fmt.Sscan(args[0], &factor); err != nil {
+			return nil, fmt.Errorf("invalid burst factor %q", args[0])
+		}
+		return c.call(http.MethodPost, "/burst", map[string]interface{}{"factor": factor, "duration": args[1]})
+	case "stop":
+		return c.call(http.MethodPost, "/stop", nil)
+	}
+	return nil, fmt.Errorf("unknown command %q", command)
+}
+
+func (c *Client) call(method string, path string, body interface{}) (*Status, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.Addr+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return nil, fmt.Errorf("%s", apiErr.Error)
+	}
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+/**********************************************************************
+**                                                                   **
+**                            Status print                           **
+**                                                                   **
+***********************************************************************/
+// Print writes the status for a terminal.
+func (s *Status) Print(w io.Writer) {
+	fmt.Fprintf(w, "state      %s\n", s.State)
+	switch {
+	case s.Interval != "":
+		fmt.Fprintf(w, "produce    %s %s (jitter %g)\n", s.ProduceMode, s.Interval, s.Jitter)
+	case s.RatePerSecond > 0:
+		fmt.Fprintf(w, "produce    %s %d rps (jitter %g)\n", s.ProduceMode, s.RatePerSecond, s.Jitter)
+	case s.DataRateLimitBPS > 0:
+		fmt.Fprintf(w, "produce    %s %d B/s (jitter %g)\n", s.ProduceMode, s.DataRateLimitBPS, s.Jitter)
+	default:
+		fmt.Fprintf(w, "produce    %s (jitter %g)\n", s.ProduceMode, s.Jitter)
+	}
+	fmt.Fprintf(w, "message    %s\n", s.MessageMode)
+	if s.Burst != nil {
+		fmt.Fprintf(w, "burst      x%g, %s left\n", s.Burst.Factor, s.Burst.Remaining)
+	}
+	for _, worker := range s.Workers {
+		state := "running"
+		if worker.Paused {
+			state = "paused"
+		}
+		if worker.TransactionalID != "" {
+			state += " (" + worker.TransactionalID + ")"
+		}
+		fmt.Fprintf(w, "worker %-3d %s\n", worker.Index, state)
+	}
+}
@@ -0,0 +1,204 @@
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"spitha/datagen/datagen/logger"
+	"strconv"
+	"time"
+)
+
+/**********************************************************************
+**                                                                   **
+**                            Control API                            **
+**                                                                   **
+***********************************************************************/
+var (
+	ErrUnknownWorker = errors.New("unknown worker")
+	ErrBurstRunning  = errors.New("a burst is already running")
+	ErrStopping      = errors.New("datagen is stopping")
+)
+
+// Generator is the running generator the API acts on.
+type Generator interface {
+	Status() Status
+	Pause(worker int) error  // 0 pauses every worker
+	Resume(worker int) error // 0 resumes every worker
+	Set(values map[string]string) error
+	Burst(factor float64, duration time.Duration) error
+	Stop() // blocks until every worker ended
+}
+
+// Status is the state of a running generator.
+type Status struct {
+	State            string         `json:"state"` // running, paused, stopping
+	ProduceMode      string         `json:"produce-mode"`
+	Interval         string         `json:"interval,omitempty"`
+	RatePerSecond    int            `json:"rate-per-second,omitempty"`
+	DataRateLimitBPS int            `json:"data-rate-limit-bps,omitempty"`
+	Jitter           float64        `json:"jitter"`
+	MessageMode      string         `json:"message-mode"`
+	Burst            *BurstStatus   `json:"burst,omitempty"`
+	Workers          []WorkerStatus `json:"workers"`
+}
+
+type BurstStatus struct {
+	Factor    float64 `json:"factor"`
+	Remaining string  `json:"remaining"`
+}
+
+type WorkerStatus struct {
+	Index           int    `json:"index"`
+	Paused          bool   `json:"paused"`
+	TransactionalID string `json:"transactional-id,omitempty"`
+}
+
+// aliases are short names for the keys tuned most often.
+var aliases = map[string]string{
+	"rate":     "datagen.produce.rate-per-second",
+	"bps":      "datagen.produce.data-rate-limit-bps",
+	"interval": "datagen.produce.interval",
+	"jitter":   "datagen.jitter",
+	"workers":  "datagen.go-routine",
+}
+
+// Serve starts the control API on listen in the background.
+func Serve(listen string, token string, g Generator) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("control api: %w", err)
+	}
+	srv := &http.Server{Handler: Handler(token, g), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Log.Error(fmt.Sprintln("control api : ", err))
+		}
+	}()
+	logger.Log.Info(fmt.Sprintln("control api listening on : ", ln.Addr()))
+	return nil
+}
+
+// Handler serves the control API. A non-empty token is required as a bearer token.
+func Handler(token string, g Generator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, g, nil)
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, g, g.Pause(0))
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, g, g.Resume(0))
+	})
+	mux.HandleFunc("POST /workers/{index}/pause", func(w http.ResponseWriter, r *http.Request) {
+		index, err := workerIndex(r)
+		if err == nil {
+			err = g.Pause(index)
+		}
+		writeStatus(w, g, err)
+	})
+	mux.HandleFunc("POST /workers/{index}/resume", func(w http.ResponseWriter, r *http.Request) {
+		index, err := workerIndex(r)
+		if err == nil {
+			err = g.Resume(index)
+		}
+		writeStatus(w, g, err)
+	})
+	mux.HandleFunc("POST /settings", func(w http.ResponseWriter, r *http.Request) {
+		// {"rate": "10k", "datagen.jitter": 0.2}
+		var body map[string]interface{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid settings: %w", err))
+			return
+		}
+		values := make(map[string]string, len(body))
+		for key, v := range body {
+			if path, ok := aliases[key]; ok {
+				key = path
+			}
+			values[key] = fmt.Sprint(v)
+		}
+		writeStatus(w, g, g.Set(values))
+	})
+	mux.HandleFunc("POST /burst", func(w http.ResponseWriter, r *http.Request) {
+		// {"factor": 5, "duration": "30s"}
+		var body struct {
+			Factor   float64 `json:"factor"`
+			Duration string  `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid burst: %w", err))
+			return
+		}
+		duration, err := time.ParseDuration(body.Duration)
+		switch {
+		case err != nil || duration <= 0:
+			err = fmt.Errorf("invalid burst duration %q, expected e.g. 30s", body.Duration)
+		case body.Factor <= 1:
+			err = fmt.Errorf("burst factor must be greater than 1")
+		default:
+			err = g.Burst(body.Factor, duration)
+		}
+		writeStatus(w, g, err)
+	})
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		status := g.Status()
+		status.State = "stopping"
+		writeJSON(w, http.StatusAccepted, status)
+		go g.Stop()
+	})
+
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+/**********************************************************************
+**                                                                   **
+**                             API utils                             **
+**                                                                   **
+***********************************************************************/
+func workerIndex(r *http.Request) (int, error) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 1 {
+		return 0, fmt.Errorf("%w %q", ErrUnknownWorker, r.PathValue("index"))
+	}
+	return index, nil
+}
+
+// writeStatus writes the current status, or err with its status code.
+func writeStatus(w http.ResponseWriter, g Generator, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, g.Status())
+	case errors.Is(err, ErrUnknownWorker):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrBurstRunning), errors.Is(err, ErrStopping):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
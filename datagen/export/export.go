@@ -0,0 +1,161 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/message"
+	"spitha/datagen/datagen/value"
+	"strings"
+)
+
+/**********************************************************************
+**                                                                   **
+**                          Dataset export                           **
+**                                                                   **
+***********************************************************************/
+// bytesRecord is the row of a message-bytes value.
+type bytesRecord struct {
+	Value string `json:"value" avro:"value" parquet:"value"`
+}
+
+// Export writes export.records generated values of the export.schema Avro
+// schema, or else of the message mode, to export.path in export.format and
+// returns the files written. A new file is started once one reaches export.max-file-size.
+func Export(cfg *config.ConfigConfig) ([]string, error) {
+	ce, dm := cfg.Export, cfg.Datagen.Message
+	path := ce.Path
+	if path == "" {
+		path = cfg.Topic.Name + "." + ce.Format
+	}
+	ex := &exporter{format: ce.Format, path: path, maxSize: int64(ce.MaxFileSize)}
+
+	var next func() interface{}
+	switch {
+	case ce.Schema != "":
+		values, err := newSchemaValues(ce.Schema)
+		if err != nil {
+			return nil, err
+		}
+		ex.sample, ex.avroSchema, next = values.sample(), values.schema.String(), values.next
+	case dm.Mode == value.MESSAGE_MODE_QUICKSTART:
+		ex.sample = message.Sample(dm.Mode, dm.QuickStart, "")
+		next = func() interface{} { return message.MakeValue(dm.Mode, dm.QuickStart, 0) }
+	case dm.Mode == value.MESSAGE_MODE_MESSAGE_BYTES:
+		ex.sample = bytesRecord{}
+		next = func() interface{} {
+			return bytesRecord{Value: string(message.MakeValue(dm.Mode, "", dm.MessageBytes.Int()).([]byte))}
+		}
+	default:
+		return nil, fmt.Errorf("export needs export.schema or datagen.message.mode %s or %s", value.MESSAGE_MODE_QUICKSTART, value.MESSAGE_MODE_MESSAGE_BYTES)
+	}
+
+	for i := int64(0); i < ce.Records; i++ {
+		if err := ex.write(next()); err != nil {
+			ex.close()
+			return ex.files, err
+		}
+	}
+	return ex.files, ex.close()
+}
+
+// exporter writes records to the current file and rotates it by size.
+type exporter struct {
+	format  string
+	path    string
+	maxSize int64 // 0 never rotates
+	sample  interface{}
+	// avroSchema is the schema of avro files, derived from sample when empty
+	avroSchema string
+	files      []string
+
+	file *os.File
+	buf  *bufio.Writer
+	size *countingWriter
+	enc  recordEncoder
+}
+
+func (ex *exporter) write(v interface{}) error {
+	if ex.enc == nil {
+		if err := ex.open(); err != nil {
+			return err
+		}
+	}
+	if err := ex.enc.encode(v); err != nil {
+		return fmt.Errorf("%s: %w", ex.file.Name(), err)
+	}
+	if ex.maxSize > 0 && ex.size.n >= ex.maxSize {
+		return ex.close()
+	}
+	return nil
+}
+
+// open starts the next file. With rotation files are numbered, e.g. users-00001.jsonl.
+func (ex *exporter) open() error {
+	name := ex.path
+	if ex.maxSize > 0 {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(name, ext), len(ex.files)+1, ext)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	ex.file, ex.buf = f, bufio.NewWriter(f)
+	ex.size = &countingWriter{w: ex.buf}
+	if ex.enc, err = newEncoder(ex.format, ex.size, ex.sample, ex.avroSchema); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	ex.files = append(ex.files, name)
+	logger.Log.Info(fmt.Sprintln("export file : ", name))
+	return nil
+}
+
+// close finishes the current file.
+func (ex *exporter) close() error {
+	if ex.enc == nil {
+		return nil
+	}
+	err := ex.enc.close()
+	if ferr := ex.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := ex.file.Close(); err == nil {
+		err = cerr
+	}
+	ex.enc = nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", ex.file.Name(), err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to a file, before they are buffered.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// deref returns the value v points to, or v.
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
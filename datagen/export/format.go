@@ -0,0 +1,230 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"spitha/datagen/datagen/message/avro"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"strings"
+
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/parquet-go/parquet-go"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           File formats                            **
+**                                                                   **
+***********************************************************************/
+// recordEncoder writes the records of a single file.
+type recordEncoder interface {
+	encode(v interface{}) error
+	close() error // writes what the format keeps buffered, e.g. the parquet footer
+}
+
+// PARQUET_ROW_GROUP_ROWS is the number of rows of a parquet row group. A file
+// only grows once a row group is written, so rotation by size is checked per row group.
+const PARQUET_ROW_GROUP_ROWS = 1000
+
+// newEncoder starts a file of format with the records of the sample type.
+// avroSchema is the schema of avro files, derived from the sample type when empty.
+func newEncoder(format string, w io.Writer, sample interface{}, avroSchema string) (recordEncoder, error) {
+	switch format {
+	case value.EXPORT_FORMAT_JSONL:
+		return jsonlEncoder{json.NewEncoder(w)}, nil
+	case value.EXPORT_FORMAT_CSV:
+		return newCsvEncoder(w, reflect.TypeOf(sample))
+	case value.EXPORT_FORMAT_AVRO:
+		schema := avroSchema
+		if schema == "" {
+			var err error
+			if schema, err = avro.Schema(sample); err != nil {
+				return nil, err
+			}
+		}
+		enc, err := ocf.NewEncoder(schema, w)
+		if err != nil {
+			return nil, err
+		}
+		return avroEncoder{enc}, nil
+	case value.EXPORT_FORMAT_PARQUET:
+		return &parquetEncoder{w: parquet.NewWriter(w, parquet.SchemaOf(sample))}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+/*******************************
+**   JSON lines
+********************************/
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e jsonlEncoder) encode(v interface{}) error { return e.enc.Encode(v) }
+func (e jsonlEncoder) close() error               { return nil }
+
+/*******************************
+**   CSV
+********************************/
+// csvEncoder writes a column per field. Nested structs are flattened into
+// dotted columns, e.g. address.city, and empty when nil.
+type csvEncoder struct {
+	w *csv.Writer
+	t reflect.Type
+}
+
+func newCsvEncoder(w io.Writer, t reflect.Type) (*csvEncoder, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv needs a struct, got %s", t.Kind())
+	}
+	e := &csvEncoder{w: csv.NewWriter(w), t: t}
+	if err := e.w.Write(csvColumns(t, "")); err != nil {
+		return nil, err
+	}
+	e.w.Flush()
+	return e, e.w.Error()
+}
+
+func (e *csvEncoder) encode(v interface{}) error {
+	row, err := csvValues(e.t, deref(reflect.ValueOf(v)), nil)
+	if err != nil {
+		return err
+	}
+	if err := e.w.Write(row); err != nil {
+		return err
+	}
+	e.w.Flush() // counted by the exporter
+	return e.w.Error()
+}
+
+func (e *csvEncoder) close() error { return nil }
+
+// csvColumns returns the column names of the fields of t, named by their json tags.
+func csvColumns(t reflect.Type, prefix string) []string {
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		if nested := structType(f.Type); nested != nil {
+			columns = append(columns, csvColumns(nested, prefix+name+".")...)
+			continue
+		}
+		columns = append(columns, prefix+name)
+	}
+	return columns
+}
+
+// csvValues appends the fields of v, a value of t or invalid when nil, to row.
+func csvValues(t reflect.Type, v reflect.Value, row []string) ([]string, error) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := fieldName(f); !ok {
+			continue
+		}
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = deref(v.Field(i))
+		}
+		if nested := structType(f.Type); nested != nil {
+			var err error
+			if row, err = csvValues(nested, fv, row); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		cell, err := csvCell(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		row = append(row, cell)
+	}
+	return row, nil
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	if !v.IsValid() {
+		return "", nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	// slices and maps as JSON
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+// fieldName returns the json name of an exported field.
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+// structType returns the struct type of a struct or struct pointer field.
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+/*******************************
+**   Avro object container
+********************************/
+type avroEncoder struct {
+	enc *ocf.Encoder
+}
+
+func (e avroEncoder) encode(v interface{}) error { return e.enc.Encode(v) }
+func (e avroEncoder) close() error               { return e.enc.Close() }
+
+/*******************************
+**   Parquet
+********************************/
+type parquetEncoder struct {
+	w    *parquet.Writer
+	rows int
+}
+
+func (e *parquetEncoder) encode(v interface{}) error {
+	if err := e.w.Write(v); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%PARQUET_ROW_GROUP_ROWS == 0 {
+		return e.w.Flush()
+	}
+	return nil
+}
+
+func (e *parquetEncoder) close() error { return e.w.Close() }
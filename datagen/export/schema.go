@@ -0,0 +1,209 @@
+package export
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/hamba/avro/v2"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           Custom schema                           **
+**                                                                   **
+***********************************************************************/
+// schemaValues generates random values of an Avro record schema. The values
+// are structs built from the schema, so every format writes them as it writes
+// the quickstart shapes.
+type schemaValues struct {
+	schema avro.Schema
+	t      reflect.Type // struct of the record
+}
+
+// newSchemaValues reads the Avro schema file at path. The schema must be a
+// record; unions may only make a type nullable, e.g. ["null", "string"].
+func newSchemaValues(path string) (*schemaValues, error) {
+	schema, err := avro.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("export.schema %s: %w", path, err)
+	}
+	if _, ok := schema.(*avro.RecordSchema); !ok {
+		return nil, fmt.Errorf("export.schema %s: must be a record, got %s", path, schema.Type())
+	}
+	t, err := schemaType(schema, map[string]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("export.schema %s: %w", path, err)
+	}
+	return &schemaValues{schema: schema, t: t}, nil
+}
+
+// sample returns a zero value, for the formats that derive columns from its type.
+func (s *schemaValues) sample() interface{} {
+	return reflect.New(s.t).Interface()
+}
+
+// next returns a random value of the schema.
+func (s *schemaValues) next() interface{} {
+	v := reflect.New(s.t)
+	randomValue(s.schema, v.Elem())
+	return v.Interface()
+}
+
+// schemaType returns the Go type of an Avro schema. records holds the records
+// being built, as a recursive record has no finite value.
+func schemaType(schema avro.Schema, records map[string]bool) (reflect.Type, error) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		return schemaType(s.Schema(), records)
+	case *avro.RecordSchema:
+		if records[s.FullName()] {
+			return nil, fmt.Errorf("record %s is recursive", s.FullName())
+		}
+		records[s.FullName()] = true
+		defer delete(records, s.FullName())
+		fields := make([]reflect.StructField, 0, len(s.Fields()))
+		for _, f := range s.Fields() {
+			t, err := schemaType(f.Type(), records)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name(), err)
+			}
+			fields = append(fields, reflect.StructField{
+				Name: "F_" + f.Name(), // exported
+				Type: t,
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%[1]s" avro:"%[1]s" parquet:"%[1]s"`, f.Name())),
+			})
+		}
+		return reflect.StructOf(fields), nil
+	case *avro.UnionSchema:
+		if !s.Nullable() {
+			return nil, fmt.Errorf("unions other than a nullable type are not supported")
+		}
+		_, typ := s.Indices()
+		t, err := schemaType(s.Types()[typ], records)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(t), nil
+	case *avro.ArraySchema:
+		t, err := schemaType(s.Items(), records)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(t), nil
+	case *avro.MapSchema:
+		t, err := schemaType(s.Values(), records)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(reflect.TypeOf(""), t), nil
+	case *avro.EnumSchema:
+		return reflect.TypeOf(""), nil
+	case *avro.FixedSchema:
+		return reflect.ArrayOf(s.Size(), reflect.TypeOf(byte(0))), nil
+	case *avro.PrimitiveSchema:
+		switch s.Type() {
+		case avro.Boolean:
+			return reflect.TypeOf(false), nil
+		case avro.Int:
+			return reflect.TypeOf(int32(0)), nil
+		case avro.Long:
+			return reflect.TypeOf(int64(0)), nil
+		case avro.Float:
+			return reflect.TypeOf(float32(0)), nil
+		case avro.Double:
+			return reflect.TypeOf(float64(0)), nil
+		case avro.String:
+			return reflect.TypeOf(""), nil
+		case avro.Bytes:
+			return reflect.TypeOf([]byte{}), nil
+		}
+	}
+	return nil, fmt.Errorf("type %s is not supported", schema.Type())
+}
+
+// randomValue sets v, a value of the Go type of schema, to random data. Logical
+// types get values in their range, e.g. timestamps of the last year.
+func randomValue(schema avro.Schema, v reflect.Value) {
+	switch s := schema.(type) {
+	case *avro.RefSchema:
+		randomValue(s.Schema(), v)
+	case *avro.RecordSchema:
+		for i, f := range s.Fields() {
+			randomValue(f.Type(), v.Field(i))
+		}
+	case *avro.UnionSchema:
+		if gofakeit.Number(1, 10) == 1 { // 1 in 10 is null
+			return
+		}
+		_, typ := s.Indices()
+		p := reflect.New(v.Type().Elem())
+		randomValue(s.Types()[typ], p.Elem())
+		v.Set(p)
+	case *avro.ArraySchema:
+		n := gofakeit.Number(1, 3)
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			randomValue(s.Items(), v.Index(i))
+		}
+	case *avro.MapSchema:
+		n := gofakeit.Number(1, 3)
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		for i := 0; i < n; i++ {
+			e := reflect.New(v.Type().Elem()).Elem()
+			randomValue(s.Values(), e)
+			v.SetMapIndex(reflect.ValueOf(gofakeit.Word()), e)
+		}
+	case *avro.EnumSchema:
+		v.SetString(s.Symbols()[gofakeit.Number(0, len(s.Symbols())-1)])
+	case *avro.FixedSchema:
+		for i := 0; i < v.Len(); i++ {
+			v.Index(i).SetUint(uint64(gofakeit.Uint8()))
+		}
+	case *avro.PrimitiveSchema:
+		randomPrimitive(s, v)
+	}
+}
+
+func randomPrimitive(s *avro.PrimitiveSchema, v reflect.Value) {
+	var logical avro.LogicalType
+	if s.Logical() != nil {
+		logical = s.Logical().Type()
+	}
+	ts := gofakeit.DateRange(time.Now().AddDate(-1, 0, 0), time.Now())
+	switch s.Type() {
+	case avro.Boolean:
+		v.SetBool(gofakeit.Bool())
+	case avro.Int:
+		switch logical {
+		case avro.Date:
+			v.SetInt(ts.Unix() / 86400)
+		case avro.TimeMillis:
+			v.SetInt(int64(gofakeit.Number(0, 86400000-1)))
+		default:
+			v.SetInt(int64(gofakeit.Number(0, 10000)))
+		}
+	case avro.Long:
+		switch logical {
+		case avro.TimestampMillis, avro.LocalTimestampMillis:
+			v.SetInt(ts.UnixMilli())
+		case avro.TimestampMicros, avro.LocalTimestampMicros:
+			v.SetInt(ts.UnixMicro())
+		case avro.TimeMicros:
+			v.SetInt(int64(gofakeit.Number(0, 86400000-1)) * 1000)
+		default:
+			v.SetInt(int64(gofakeit.Number(0, 1000000)))
+		}
+	case avro.Float, avro.Double:
+		v.SetFloat(gofakeit.Float64Range(0, 1000))
+	case avro.String:
+		if logical == avro.UUID {
+			v.SetString(gofakeit.UUID())
+			return
+		}
+		v.SetString(gofakeit.Word())
+	case avro.Bytes:
+		v.SetBytes([]byte(gofakeit.LetterN(8)))
+	}
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hamba/avro/v2"
+)
+
+func TestSchemaValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name: "every type",
+			schema: `{"type":"record","name":"Order","fields":[
+				{"name":"id","type":{"type":"string","logicalType":"uuid"}},
+				{"name":"qty","type":"int"},
+				{"name":"total","type":"double"},
+				{"name":"ratio","type":"float"},
+				{"name":"paid","type":"boolean"},
+				{"name":"created","type":{"type":"long","logicalType":"timestamp-millis"}},
+				{"name":"day","type":{"type":"int","logicalType":"date"}},
+				{"name":"at","type":{"type":"long","logicalType":"time-micros"}},
+				{"name":"status","type":{"type":"enum","name":"Status","symbols":["NEW","PAID"]}},
+				{"name":"note","type":["null","string"]},
+				{"name":"tags","type":{"type":"array","items":"string"}},
+				{"name":"attrs","type":{"type":"map","values":"long"}},
+				{"name":"hash","type":{"type":"fixed","name":"Hash","size":4}},
+				{"name":"raw","type":"bytes"},
+				{"name":"ship","type":["null",{"type":"record","name":"Address","fields":[{"name":"city","type":"string"}]}]},
+				{"name":"bill","type":"Address"}
+			]}`,
+		},
+		{name: "not a record", schema: `"string"`, wantErr: "must be a record"},
+		{name: "union", schema: `{"type":"record","name":"R","fields":[{"name":"id","type":["int","string"]}]}`, wantErr: "field id: unions"},
+		{name: "recursive", schema: `{"type":"record","name":"Node","fields":[{"name":"next","type":["null","Node"]}]}`, wantErr: "record Node is recursive"},
+		{name: "invalid", schema: `{"type":"record"}`, wantErr: "export.schema"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "schema.avsc")
+		if err := os.WriteFile(path, []byte(tt.schema), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		values, err := newSchemaValues(path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// values encode with the schema and decode to the same shape
+		for i := 0; i < 100; i++ {
+			b, err := avro.Marshal(values.schema, values.next())
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", tt.name, err)
+			}
+			got := values.sample()
+			if err := avro.Unmarshal(values.schema, b, got); err != nil {
+				t.Fatalf("%s: unmarshal: %v", tt.name, err)
+			}
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package datagen
 
 import (
+	"fmt"
+	"os"
 	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/control"
+	"spitha/datagen/datagen/export"
 	"spitha/datagen/datagen/logger"
 	"spitha/datagen/datagen/producer"
 )
@@ -11,14 +15,108 @@ import (
 **                               Handler                             **
 **                                                                   **
 ***********************************************************************/
-func Handler(configPath string) {
+func Handler(configPath string, overrides config.Overrides) {
 
 	// Init logger
 	logger.InitLogger()
 
 	// Init config
-	config := config.InitConfig(configPath)
+	config := config.InitConfig(configPath, overrides)
 
 	// datagen
 	producer.Datagen(config)
 }
+
+/**********************************************************************
+**                                                                   **
+**                          Validate Handler                         **
+**                                                                   **
+***********************************************************************/
+// Validate prints every problem of the config and exits non-zero if there is any.
+func Validate(configPath string, overrides config.Overrides) {
+	if _, err := config.Load(configPath, overrides); err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", configPath)
+}
+
+/**********************************************************************
+**                                                                   **
+**                        Print Config Handler                       **
+**                                                                   **
+***********************************************************************/
+// PrintConfig prints the effective config (file, environment and flags merged) with secrets redacted.
+func PrintConfig(configPath string, overrides config.Overrides) {
+	cfg, err := config.Load(configPath, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+		os.Exit(1)
+	}
+	out, err := cfg.RedactedYAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(string(out))
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Control Handler                          **
+**                                                                   **
+***********************************************************************/
+// Ctl runs a command against the control api of a running datagen and prints its status.
+func Ctl(addr string, token string, command string, args []string) {
+	status, err := control.NewClient(addr, token).Run(command, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	status.Print(os.Stdout)
+}
+
+/**********************************************************************
+**                                                                   **
+**                         Preflight Handler                         **
+**                                                                   **
+***********************************************************************/
+// Preflight checks the cluster and the schema registry of the config, prints a
+// pass/fail table and exits non-zero if any check failed.
+func Preflight(configPath string, overrides config.Overrides) {
+	logger.InitLogger()
+	cfg, err := config.Load(configPath, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+		os.Exit(1)
+	}
+	report := producer.Preflight(cfg)
+	report.Print(os.Stdout)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+/**********************************************************************
+**                                                                   **
+**                           Export Handler                          **
+**                                                                   **
+***********************************************************************/
+// Export writes a dataset of generated values to files without connecting to
+// Kafka and prints the files written.
+func Export(configPath string, overrides config.Overrides) {
+	logger.InitLogger()
+	cfg, err := config.LoadOffline(configPath, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+		os.Exit(1)
+	}
+	files, err := export.Export(cfg)
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
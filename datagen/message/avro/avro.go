@@ -1,21 +1,21 @@
 package avro
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"spitha/datagen/datagen/logger"
-	"spitha/datagen/datagen/message/quickstart"
-	"spitha/datagen/datagen/value"
 	"strings"
 
 	"github.com/hamba/avro/v2"
 	"github.com/twmb/franz-go/pkg/sr"
 )
 
-func HelperAvro(quickstartType string, srClient *sr.Client, serde *sr.Serde, subjectName string) {
-	// avro
+// Schema returns the Avro schema of sample, a quickstart struct or []byte.
+func Schema(sample interface{}) (string, error) {
+	if _, ok := sample.([]byte); ok {
+		return `"bytes"`, nil
+	}
 	// schema template
 	var schemaTemplate = `{
 					"type": "record",
@@ -23,52 +23,24 @@ func HelperAvro(quickstartType string, srClient *sr.Client, serde *sr.Serde, sub
 					"namespace": "datagen.spitha.io",
 					"fields" : []
 				}`
-	var schemaSrc interface{}
-	switch quickstartType {
-	case value.QUICKSTART_USER:
-		schemaSrc = quickstart.PersonInfo{}
-	case value.QUICKSTART_BOOK:
-		schemaSrc = quickstart.BookInfo{}
-	case value.QUICKSTART_CAR:
-		schemaSrc = quickstart.CarInfo{}
-	case value.QUICKSTART_ADDRESS:
-		schemaSrc = quickstart.AddressInfo{}
-	case value.QUICKSTART_CONTACT:
-		schemaSrc = quickstart.ContactInfo{}
-	case value.QUICKSTART_MOVIE:
-		schemaSrc = quickstart.MovieInfo{}
-	case value.QUICKSTART_JOB:
-		schemaSrc = quickstart.JobInfo{}
-	}
-
-	// add quickstart field
-	schema, err := generateAvroSchema(schemaTemplate, schemaSrc)
+	schema, err := generateAvroSchema(schemaTemplate, sample)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintln("Error generating schema:", err))
-		panic(err)
+		return "", fmt.Errorf("generating avro schema: %w", err)
 	}
 	logger.Log.Debug(schema)
+	return schema, nil
+}
 
-	// find schema in schema registry
-	schemaRegistrySchema, err := srClient.CreateSchema(context.Background(), subjectName, sr.Schema{
-		Schema: schema,
-		Type:   sr.TypeAvro,
-	})
-	if err != nil {
-		panic(err)
-	}
-	logger.Log.Info(fmt.Sprintf("created or reusing schema subject %q version %d id %d\n", schemaRegistrySchema.Subject, schemaRegistrySchema.Version, schemaRegistrySchema.ID))
-
-	// avro parse
+// Register encodes values of the sample type with schema, registered as id.
+// The schema may be a pre-registered one; fields are matched by name.
+func Register(serde *sr.Serde, id int, schema string, sample interface{}) error {
 	avroSchema, err := avro.Parse(schema)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("schema id %d: %w", id, err)
 	}
-
-	// serde register
 	serde.Register(
-		schemaRegistrySchema.ID,
-		schemaSrc,
+		id,
+		sample,
 		sr.EncodeFn(func(v any) ([]byte, error) {
 			return avro.Marshal(avroSchema, v)
 		}),
@@ -76,6 +48,7 @@ func HelperAvro(quickstartType string, srClient *sr.Client, serde *sr.Serde, sub
 			return avro.Unmarshal(avroSchema, b, v)
 		}),
 	)
+	return nil
 }
 
 // generate avro schema for schema registry template
@@ -0,0 +1,123 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/**********************************************************************
+**                                                                   **
+**                     Pre-registered schema check                   **
+**                                                                   **
+***********************************************************************/
+// Check reports whether values of the sample type are valid for schema, a
+// pre-registered JSON schema: every property of the values must have a
+// compatible type, every required property must be in the values, and a
+// schema without additional properties must have every property of the values.
+func Check(schema string, sample interface{}) error {
+	generated, err := Schema(sample)
+	if err != nil {
+		return err
+	}
+	var registered, values map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &registered); err != nil {
+		return fmt.Errorf("parse json schema: %w", err)
+	}
+	if err := json.Unmarshal([]byte(generated), &values); err != nil {
+		return err
+	}
+	var problems []string
+	checkSchema(registered, values, "", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("json schema does not match the values: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkSchema compares the schema of the values at path with the registered one.
+func checkSchema(registered, values map[string]interface{}, path string, problems *[]string) {
+	// a nullable value is checked as its type
+	if oneOf, ok := values["oneOf"].([]interface{}); ok && len(oneOf) == 2 {
+		values, _ = oneOf[1].(map[string]interface{})
+	}
+	name := path
+	if name == "" {
+		name = "the value"
+	}
+	typ, _ := values["type"].(string)
+	if !allowsType(registered["type"], typ) {
+		*problems = append(*problems, fmt.Sprintf("%s: type %v, the values are %s", name, registered["type"], typ))
+		return
+	}
+
+	switch typ {
+	case "object":
+		properties, _ := registered["properties"].(map[string]interface{})
+		valueProperties, _ := values["properties"].(map[string]interface{})
+		for _, required := range stringList(registered["required"]) {
+			if _, ok := valueProperties[required]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: required property %s is not in the values", name, joinPath(path, required)))
+			}
+		}
+		names := make([]string, 0, len(valueProperties))
+		for property := range valueProperties {
+			names = append(names, property)
+		}
+		sort.Strings(names)
+		for _, property := range names {
+			r, ok := properties[property].(map[string]interface{})
+			if !ok {
+				if registered["additionalProperties"] == false {
+					*problems = append(*problems, fmt.Sprintf("%s: property %s is not allowed", name, joinPath(path, property)))
+				}
+				continue
+			}
+			v, _ := valueProperties[property].(map[string]interface{})
+			checkSchema(r, v, joinPath(path, property), problems)
+		}
+	case "array":
+		r, rok := registered["items"].(map[string]interface{})
+		v, vok := values["items"].(map[string]interface{})
+		if rok && vok {
+			checkSchema(r, v, path+"[]", problems)
+		}
+	}
+}
+
+// allowsType reports whether the registered type, a name, a list of names or
+// missing, allows values of typ. An integer is a number.
+func allowsType(registered interface{}, typ string) bool {
+	if registered == nil {
+		return true
+	}
+	types := stringList(registered)
+	if s, ok := registered.(string); ok {
+		types = []string{s}
+	}
+	for _, t := range types {
+		if t == typ || t == "number" && typ == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var s []string
+	for _, e := range list {
+		if str, ok := e.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
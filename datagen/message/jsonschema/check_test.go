@@ -0,0 +1,93 @@
+package jsonschema
+
+import (
+	"os"
+	"spitha/datagen/datagen/logger"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+type testAddress struct {
+	City string `json:"city"`
+}
+
+type testUser struct {
+	Name    string       `json:"name"`
+	Age     int          `json:"age"`
+	Score   float64      `json:"score"`
+	Tags    []string     `json:"tags"`
+	Address *testAddress `json:"address"`
+}
+
+func TestCheck(t *testing.T) {
+	generated, err := Schema(testUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		schema  string
+		sample  interface{}
+		wantErr string
+	}{
+		{name: "generated", schema: generated, sample: testUser{}},
+		{
+			name: "looser types and other properties",
+			schema: `{"type":"object","properties":{
+				"name":{"type":["string","null"]},
+				"age":{"type":"number"},
+				"score":{},
+				"tags":{"type":"array"},
+				"address":{"type":"object","properties":{"city":{"type":"string"}}},
+				"email":{"type":"string"}
+			},"required":["name"]}`,
+			sample: testUser{},
+		},
+		{
+			name:    "property type",
+			schema:  `{"type":"object","properties":{"age":{"type":"string"}}}`,
+			sample:  testUser{},
+			wantErr: "age: type string, the values are integer",
+		},
+		{
+			name:    "nested property type",
+			schema:  `{"type":"object","properties":{"address":{"type":"object","properties":{"city":{"type":"integer"}}},"tags":{"type":"array","items":{"type":"integer"}}}}`,
+			sample:  testUser{},
+			wantErr: "address.city: type integer, the values are string; tags[]: type integer, the values are string",
+		},
+		{
+			name:    "required property",
+			schema:  `{"type":"object","required":["name","email"]}`,
+			sample:  testUser{},
+			wantErr: "required property email is not in the values",
+		},
+		{
+			name:    "additional property",
+			schema:  `{"type":"object","properties":{"name":{"type":"string"}},"additionalProperties":false}`,
+			sample:  testUser{},
+			wantErr: "property address is not allowed",
+		},
+		{name: "bytes", schema: `{"type":"string"}`, sample: []byte{}},
+		{name: "bytes as object", schema: `{"type":"object"}`, sample: []byte{}, wantErr: "the value: type object, the values are string"},
+		{name: "not json", schema: `syntax = "proto3";`, sample: testUser{}, wantErr: "parse json schema"},
+	}
+	for _, tt := range tests {
+		err := Check(tt.schema, tt.sample)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"spitha/datagen/datagen/logger"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/sr"
+)
+
+const JSON_SCHEMA_DRAFT = "http://json-schema.org/draft-07/schema#"
+
+// Schema returns the JSON schema of sample, a quickstart struct or []byte.
+func Schema(sample interface{}) (string, error) {
+	var schema map[string]interface{}
+	if _, ok := sample.([]byte); ok {
+		// message-bytes payloads are written as a JSON string
+		schema = map[string]interface{}{"type": "string"}
+	} else {
+		t := reflect.TypeOf(sample)
+		if t == nil {
+			return "", fmt.Errorf("src is nil")
+		}
+		var err error
+		if schema, err = typeSchema(deref(t)); err != nil {
+			return "", fmt.Errorf("generating json schema: %w", err)
+		}
+		schema["title"] = t.Name()
+	}
+	schema["$schema"] = JSON_SCHEMA_DRAFT
+
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("marshal final schema: %w", err)
+	}
+	logger.Log.Debug(string(schemaBytes))
+	return string(schemaBytes), nil
+}
+
+// Register encodes values of the sample type as JSON, registered as id.
+func Register(serde *sr.Serde, id int, sample interface{}) {
+	serde.Register(
+		id,
+		sample,
+		sr.EncodeFn(func(v any) ([]byte, error) {
+			if b, ok := v.([]byte); ok {
+				return json.Marshal(string(b))
+			}
+			return json.Marshal(v)
+		}),
+		sr.DecodeFn(func(b []byte, v any) error {
+			return json.Unmarshal(b, v)
+		}),
+	)
+}
+
+// --- internals ---
+func typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+
+	case reflect.Slice, reflect.Array:
+		// []byte is encoded as a base64 string
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := typeSchema(deref(t.Elem()))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key must be string for JSON, got %s", t.Key())
+		}
+		values, err := typeSchema(deref(t.Elem()))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+
+	case reflect.Struct:
+		// time.Time is an RFC 3339 string
+		if t.PkgPath() == "time" && t.Name() == "Time" {
+			return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+		}
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			// skip unexported fields
+			if f.PkgPath != "" {
+				continue
+			}
+			name, omitempty := parseJSONTag(f.Tag.Get("json"))
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			property, err := typeSchema(deref(f.Type))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			// pointer => nullable
+			if f.Type.Kind() == reflect.Ptr {
+				property = map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"type": "null"}, property}}
+			} else if !omitempty {
+				required = append(required, name)
+			}
+			properties[name] = property
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %s", t.String())
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func parseJSONTag(tag string) (name string, omitempty bool) {
+	parts := strings.Split(tag, ",")
+	for _, p := range parts[1:] {
+		if p == "omitempty" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty
+}
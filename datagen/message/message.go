@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"reflect"
 	"spitha/datagen/datagen/logger"
 	"spitha/datagen/datagen/message/quickstart"
 	"spitha/datagen/datagen/value"
@@ -64,14 +65,84 @@ func MakeRatePerSecondJitter(produceMode string, defaultValue int, jitterRate fl
 **                            Make Message                           **
 **                                                                   **
 ***********************************************************************/
+// MakeMessage generates a record of the message mode. With a schema registry
+// type the value is encoded by serde in the Confluent wire format, otherwise
+// quickstart values are JSON and message-bytes values raw bytes.
 func MakeMessage(serde *sr.Serde, messageMode string, quickstartType string, messageBytes int, schemaRegistryMessageType string) *kgo.Record {
+	var msgKey []byte
+	var msgValue interface{}
+	switch messageMode {
+	case value.MESSAGE_MODE_QUICKSTART:
+		key, v := makeQuickstartMessage(quickstartType, schemaRegistryMessageType)
+		var err error
+		if msgKey, err = json.Marshal(key); err != nil {
+			logger.Log.Error(fmt.Sprintln(err))
+			return &kgo.Record{}
+		}
+		msgValue = v
+	case value.MESSAGE_MODE_MESSAGE_BYTES:
+		msgValue = makeMessageBytes(messageBytes)
+	default:
+		return &kgo.Record{}
+	}
+
+	msgByteValue, err := encodeValue(serde, msgValue, schemaRegistryMessageType)
+	if err != nil {
+		logger.Log.Error(fmt.Sprintln(err))
+		return &kgo.Record{}
+	}
+	return &kgo.Record{
+		Key:       msgKey,
+		Value:     msgByteValue,
+		Timestamp: time.Now(),
+	}
+}
+
+// Sample returns a zero value of the type generated for the message mode,
+// from which the schema is derived and under which the encoder is registered.
+func Sample(messageMode string, quickstartType string, schemaRegistryMessageType string) interface{} {
+	switch messageMode {
+	case value.MESSAGE_MODE_QUICKSTART:
+		_, v := makeQuickstartMessage(quickstartType, schemaRegistryMessageType)
+		t := reflect.TypeOf(v)
+		if t == nil {
+			return nil
+		}
+		if t.Kind() == reflect.Ptr {
+			return reflect.New(t.Elem()).Interface()
+		}
+		return reflect.Zero(t).Interface()
+	case value.MESSAGE_MODE_MESSAGE_BYTES:
+		return []byte{}
+	}
+	return nil
+}
+
+// MakeValue returns a generated value before it is encoded: a quickstart struct
+// or the message-bytes bytes.
+func MakeValue(messageMode string, quickstartType string, messageBytes int) interface{} {
 	switch messageMode {
 	case value.MESSAGE_MODE_QUICKSTART:
-		return makeQuickstartMessage(serde, quickstartType, schemaRegistryMessageType)
+		_, v := makeQuickstartMessage(quickstartType, "")
+		return v
 	case value.MESSAGE_MODE_MESSAGE_BYTES:
 		return makeMessageBytes(messageBytes)
 	}
-	return &kgo.Record{}
+	return nil
+}
+
+func encodeValue(serde *sr.Serde, msgValue interface{}, schemaRegistryMessageType string) ([]byte, error) {
+	// schema registry
+	if schemaRegistryMessageType != "" {
+		if serde == nil {
+			return nil, fmt.Errorf("schema registry type %s without a registered schema", schemaRegistryMessageType)
+		}
+		return serde.Encode(msgValue)
+	}
+	if b, ok := msgValue.([]byte); ok {
+		return b, nil
+	}
+	return json.Marshal(msgValue)
 }
 
 /**********************************************************************
@@ -79,7 +150,9 @@ func MakeMessage(serde *sr.Serde, messageMode string, quickstartType string, mes
 **                       Make Quickstart Message                     **
 **                                                                   **
 ***********************************************************************/
-func makeQuickstartMessage(serde *sr.Serde, quickstartType string, schemaRegistryMessageType string) *kgo.Record {
+// makeQuickstartMessage returns the key and the value of a quickstart record.
+// Protobuf needs the generated message types.
+func makeQuickstartMessage(quickstartType string, schemaRegistryMessageType string) (interface{}, interface{}) {
 	var msgValue interface{}
 	var msgKey interface{}
 	switch quickstartType {
@@ -161,33 +234,7 @@ func makeQuickstartMessage(serde *sr.Serde, quickstartType string, schemaRegistr
 			msgKey = randomData.Title
 		}
 	}
-	msgByteValue, err := json.Marshal(msgValue)
-	if err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		return &kgo.Record{}
-	}
-	msgByteKey, err := json.Marshal(msgKey)
-	if err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		return &kgo.Record{}
-	}
-	// schema registry
-	if schemaRegistryMessageType != "" {
-		if serde == nil {
-			panic("serde is nil")
-		}
-		return &kgo.Record{
-			Key:       msgByteKey,
-			Value:     serde.MustEncode(msgValue),
-			Timestamp: time.Now(),
-		}
-	} else {
-		return &kgo.Record{
-			Key:       msgByteKey,
-			Value:     msgByteValue,
-			Timestamp: time.Now(),
-		}
-	}
+	return msgKey, msgValue
 }
 
 /**********************************************************************
@@ -195,6 +242,6 @@ func makeQuickstartMessage(serde *sr.Serde, quickstartType string, schemaRegistr
 **                          Make Message Bytes                       **
 **                                                                   **
 ***********************************************************************/
-func makeMessageBytes(messageMaxBytes int) *kgo.Record {
-	return kgo.SliceRecord([]byte(strings.Repeat("A", messageMaxBytes)))
+func makeMessageBytes(messageMaxBytes int) []byte {
+	return []byte(strings.Repeat("A", messageMaxBytes))
 }
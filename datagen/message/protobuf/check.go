@@ -0,0 +1,265 @@
+package protobuf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/**********************************************************************
+**                                                                   **
+**                     Pre-registered schema check                   **
+**                                                                   **
+***********************************************************************/
+// protoMessage is a message of a .proto schema, as far as Check needs it.
+type protoMessage struct {
+	name   string
+	fields map[int32]protoField // by number
+	nested map[string]*protoMessage
+}
+
+type protoField struct {
+	name string
+	typ  string // as written, e.g. string, JobInfo, map<string,int64>
+}
+
+// Check reports whether values of the sample type can be encoded with schema,
+// a pre-registered .proto schema: values are encoded as its first message, so
+// that message must have the name of the sample message and every field of
+// the sample with the same number, name and type.
+func Check(schema string, sample interface{}) error {
+	messages, err := parseProto(schema)
+	if err != nil {
+		return fmt.Errorf("parse protobuf schema: %w", err)
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("protobuf schema has no message")
+	}
+	first := messages[0]
+
+	if _, ok := sample.([]byte); ok {
+		if f, ok := first.fields[1]; !ok || f.typ != "bytes" {
+			return fmt.Errorf("protobuf message %s must have field 1 of type bytes for message-bytes values", first.name)
+		}
+		return nil
+	}
+	pm, ok := sample.(proto.Message)
+	if !ok {
+		return fmt.Errorf("no protobuf message for %T", sample)
+	}
+	desc := pm.ProtoReflect().Descriptor()
+	if string(desc.Name()) != first.name {
+		return fmt.Errorf("protobuf schema message %s, the values are %s", first.name, desc.Name())
+	}
+	var problems []string
+	checkMessage(desc, first, messages, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("protobuf schema message %s does not match the values: %s", first.name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkMessage compares the fields of desc with those of m, and the messages
+// of message fields with the schema messages of the same name.
+func checkMessage(desc protoreflect.MessageDescriptor, m *protoMessage, top []*protoMessage, problems *[]string) {
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f, ok := m.fields[int32(fd.Number())]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s.%s: no field %d", m.name, fd.Name(), fd.Number()))
+			continue
+		}
+		if f.name != string(fd.Name()) {
+			*problems = append(*problems, fmt.Sprintf("%s: field %d is %s, the values have %s", m.name, fd.Number(), f.name, fd.Name()))
+			continue
+		}
+		want := fieldType(fd)
+		if typeName(f.typ) != want {
+			*problems = append(*problems, fmt.Sprintf("%s.%s: type %s, the values have %s", m.name, f.name, f.typ, want))
+			continue
+		}
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsMap() {
+			if nested := findMessage(want, m, top); nested != nil {
+				checkMessage(fd.Message(), nested, top, problems)
+			}
+		}
+	}
+}
+
+// fieldType returns the type of fd as a .proto schema names it, without package.
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return fmt.Sprintf("map<%s,%s>", fieldType(fd.MapKey()), fieldType(fd.MapValue()))
+	case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+		return string(fd.Message().Name())
+	case fd.Kind() == protoreflect.EnumKind:
+		return string(fd.Enum().Name())
+	}
+	return fd.Kind().String()
+}
+
+// typeName drops the package and the enclosing messages of a type, e.g.
+// spitha.events.PersonInfo.JobInfo -> JobInfo.
+func typeName(typ string) string {
+	if strings.HasPrefix(typ, "map<") {
+		return typ
+	}
+	return typ[strings.LastIndex(typ, ".")+1:]
+}
+
+// findMessage looks name up in the messages nested in m, then at the top level.
+func findMessage(name string, m *protoMessage, top []*protoMessage) *protoMessage {
+	if nested, ok := m.nested[name]; ok {
+		return nested
+	}
+	for _, t := range top {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+/*******************************
+**   .proto parser
+********************************/
+// protoParser reads the messages of a .proto schema. Everything but messages,
+// their fields, nested messages and oneofs is skipped.
+type protoParser struct {
+	s   scanner.Scanner
+	tok rune
+	err error
+}
+
+// parseProto returns the top-level messages of schema in order.
+func parseProto(schema string) ([]*protoMessage, error) {
+	p := &protoParser{}
+	p.s.Init(strings.NewReader(schema))
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
+	p.s.IsIdentRune = func(ch rune, i int) bool {
+		return ch == '_' || ch == '.' && i > 0 || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' && i > 0
+	}
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%s: %s", s.Position, msg)
+		}
+	}
+	p.next()
+
+	var messages []*protoMessage
+	for p.tok != scanner.EOF && p.err == nil {
+		if p.text() == "message" {
+			p.next()
+			messages = append(messages, p.message())
+			continue
+		}
+		p.skipStatement()
+	}
+	return messages, p.err
+}
+
+func (p *protoParser) next()        { p.tok = p.s.Scan() }
+func (p *protoParser) text() string { return p.s.TokenText() }
+
+func (p *protoParser) expect(s string) {
+	if p.err == nil && p.text() != s {
+		p.err = fmt.Errorf("%s: expected %q, got %q", p.s.Position, s, p.text())
+	}
+	p.next()
+}
+
+// message parses the name and the body of a message.
+func (p *protoParser) message() *protoMessage {
+	m := &protoMessage{name: p.text(), fields: make(map[int32]protoField), nested: make(map[string]*protoMessage)}
+	p.next()
+	p.expect("{")
+	p.body(m)
+	return m
+}
+
+// body parses the declarations of a message or oneof up to its closing brace.
+func (p *protoParser) body(m *protoMessage) {
+	for p.err == nil {
+		switch p.text() {
+		case "}":
+			p.next()
+			return
+		case "":
+			p.err = fmt.Errorf("message %s is not closed", m.name)
+			return
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			nested := p.message()
+			m.nested[nested.name] = nested
+		case "oneof":
+			p.next() // the fields of a oneof are fields of the message
+			p.next()
+			p.expect("{")
+			p.body(m)
+		case "enum", "extend", "extensions", "reserved", "option":
+			p.skipStatement()
+		default:
+			p.field(m)
+		}
+	}
+}
+
+// field parses `[repeated|optional|required] type name = number [options];`.
+func (p *protoParser) field(m *protoMessage) {
+	switch p.text() {
+	case "repeated", "optional", "required":
+		p.next()
+	}
+	typ := p.text()
+	p.next()
+	if typ == "map" {
+		p.expect("<")
+		key := p.text()
+		p.next()
+		p.expect(",")
+		value := p.text()
+		p.next()
+		p.expect(">")
+		typ = fmt.Sprintf("map<%s,%s>", key, typeName(value))
+	}
+	name := p.text()
+	p.next()
+	p.expect("=")
+	number, err := strconv.ParseInt(p.text(), 0, 32)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: field %s: invalid number %q", p.s.Position, name, p.text())
+	}
+	p.skipStatement() // options
+	m.fields[int32(number)] = protoField{name: name, typ: typ}
+}
+
+// skipStatement skips up to the end of a statement or block.
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.tok != scanner.EOF {
+		switch p.text() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth <= 0 {
+				p.next()
+				return
+			}
+		case ";":
+			if depth == 0 {
+				p.next()
+				return
+			}
+		}
+		p.next()
+	}
+}
@@ -0,0 +1,102 @@
+package protobuf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckEmbeddedSchemas(t *testing.T) {
+	samples := []interface{}{&AddressInfo{}, &BookInfo{}, &CarInfo{}, &ContactInfo{}, &CreditCardInfo{}, &JobInfo{}, &MovieInfo{}, &PersonInfo{}, []byte{}}
+	for _, sample := range samples {
+		schema, err := Schema(sample)
+		if err != nil {
+			t.Fatalf("%T: %v", sample, err)
+		}
+		if err := Check(schema, sample); err != nil {
+			t.Errorf("%T: %v", sample, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		sample  interface{}
+		wantErr string
+	}{
+		{
+			name: "options, comments and other messages",
+			schema: `syntax = "proto3";
+				package other.events; // another package
+				import "google/protobuf/timestamp.proto";
+				/* the values are CarInfo */
+				message CarInfo {
+					option deprecated = false;
+					reserved 9;
+					enum Kind { SEDAN = 0; }
+					string type = 1 [json_name = "type"];
+					string fuel = 2;
+					oneof gearbox { string transmission = 3; }
+					string brand = 4;
+					string model = 5;
+					int64 year = 6;
+					map<string, string> extra = 7;
+				}
+				message Unused { int32 id = 1; }`,
+			sample: &CarInfo{},
+		},
+		{
+			name:    "other message first",
+			schema:  `syntax = "proto3"; message Unused { int32 id = 1; } message CarInfo { string type = 1; }`,
+			sample:  &CarInfo{},
+			wantErr: "protobuf schema message Unused, the values are CarInfo",
+		},
+		{
+			name:    "field number",
+			schema:  `message CarInfo { string type = 1; string fuel = 2; string transmission = 3; string brand = 4; string model = 5; int64 year = 7; }`,
+			sample:  &CarInfo{},
+			wantErr: "CarInfo.year: no field 6",
+		},
+		{
+			name:    "field name",
+			schema:  `message CarInfo { string kind = 1; string fuel = 2; string transmission = 3; string brand = 4; string model = 5; int64 year = 6; }`,
+			sample:  &CarInfo{},
+			wantErr: "CarInfo: field 1 is kind, the values have type",
+		},
+		{
+			name:    "field type",
+			schema:  `message CarInfo { string type = 1; string fuel = 2; string transmission = 3; string brand = 4; string model = 5; int32 year = 6; }`,
+			sample:  &CarInfo{},
+			wantErr: "CarInfo.year: type int32, the values have int64",
+		},
+		{
+			name: "nested message",
+			schema: `message PersonInfo {
+				message JobInfo { string company = 1; string title = 2; string descriptor = 3; int32 level = 4; }
+				message AddressInfo { string address = 1; string street = 2; string city = 3; string state = 4; string zip = 5; string country = 6; double latitude = 7; double longitude = 8; }
+				message ContactInfo { string phone = 1; string email = 2; }
+				message CreditCardInfo { string type = 1; string number = 2; string exp = 3; string cvv = 4; }
+				string first_name = 1; string last_name = 2; string gender = 3; string ssn = 4; string hobby = 5;
+				JobInfo job = 6; AddressInfo address = 7; ContactInfo contact = 8; CreditCardInfo credit_card = 9;
+			}`,
+			sample:  &PersonInfo{},
+			wantErr: "JobInfo.level: type int32, the values have string",
+		},
+		{name: "bytes", schema: `message Payload { string payload = 1; }`, sample: []byte{}, wantErr: "field 1 of type bytes"},
+		{name: "no message", schema: `syntax = "proto3";`, sample: &CarInfo{}, wantErr: "no message"},
+		{name: "not closed", schema: `message CarInfo { string type = 1;`, sample: &CarInfo{}, wantErr: "parse protobuf schema"},
+	}
+	for _, tt := range tests {
+		err := Check(tt.schema, tt.sample)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -1,56 +1,62 @@
 package protobuf
 
 import (
-	"context"
 	"embed"
 	"fmt"
-	"spitha/datagen/datagen/value"
+	"path"
 
 	"github.com/twmb/franz-go/pkg/sr"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
 //go:embed *.proto
 var protoFS embed.FS
 
-func HelperProtobuf(quickstartType string, srClient *sr.Client, serde *sr.Serde, subjectName string) {
-	type ent struct {
-		file   string
-		sample proto.Message
-		index  int
-	}
-	m := map[string]ent{
-		value.QUICKSTART_USER:    {"person.proto", &PersonInfo{}, 0},
-		value.QUICKSTART_BOOK:    {"book.proto", &BookInfo{}, 0},
-		value.QUICKSTART_CAR:     {"car.proto", &CarInfo{}, 0},
-		value.QUICKSTART_ADDRESS: {"address.proto", &AddressInfo{}, 0},
-		value.QUICKSTART_CONTACT: {"contact.proto", &ContactInfo{}, 0},
-		value.QUICKSTART_MOVIE:   {"movie.proto", &MovieInfo{}, 0},
-		value.QUICKSTART_JOB:     {"job.proto", &JobInfo{}, 0},
-	}
+// BYTES_SCHEMA wraps the payload of the message-bytes mode.
+const BYTES_SCHEMA = `syntax = "proto3";
 
-	e, ok := m[quickstartType]
-	if !ok {
-		panic(fmt.Errorf("unknown quickstart type: %s", quickstartType))
-	}
+package spitha.events;
 
-	protoBytes, err := protoFS.ReadFile(e.file)
-	if err != nil {
-		panic(fmt.Errorf("read proto file %q: %w", e.file, err))
-	}
-	protoText := string(protoBytes)
+message Payload {
+  bytes payload = 1;
+}
+`
 
-	ss, err := srClient.CreateSchema(context.Background(), subjectName, sr.Schema{
-		Schema: protoText,
-		Type:   sr.TypeProtobuf,
-	})
-	if err != nil {
-		panic(err)
+// Schema returns the .proto schema of sample, a quickstart message or []byte.
+func Schema(sample interface{}) (string, error) {
+	switch s := sample.(type) {
+	case []byte:
+		return BYTES_SCHEMA, nil
+	case proto.Message:
+		file := path.Base(s.ProtoReflect().Descriptor().ParentFile().Path())
+		protoBytes, err := protoFS.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read proto file %q: %w", file, err)
+		}
+		return string(protoBytes), nil
 	}
+	return "", fmt.Errorf("no protobuf schema for %T", sample)
+}
 
+// Register encodes values of the sample type as the first message of the
+// schema registered as id.
+func Register(serde *sr.Serde, id int, sample interface{}) {
+	if _, ok := sample.([]byte); ok {
+		serde.Register(
+			id,
+			sample,
+			sr.EncodeFn(func(v any) ([]byte, error) {
+				b := protowire.AppendTag(nil, 1, protowire.BytesType)
+				return protowire.AppendBytes(b, v.([]byte)), nil
+			}),
+			sr.Index(0),
+		)
+		return
+	}
 	serde.Register(
-		ss.ID,
-		e.sample,
+		id,
+		sample,
 		sr.EncodeFn(func(v any) ([]byte, error) {
 			pm, ok := v.(proto.Message)
 			if !ok {
@@ -65,6 +71,6 @@ func HelperProtobuf(quickstartType string, srClient *sr.Client, serde *sr.Serde,
 			}
 			return proto.Unmarshal(b, pm)
 		}),
-		sr.Index(e.index),
+		sr.Index(0),
 	)
 }
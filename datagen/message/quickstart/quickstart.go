@@ -13,22 +13,22 @@ import (
 **                                                                   **
 ***********************************************************************/
 type PersonInfo struct {
-	FirstName  string          `json:"first_name" xml:"first_name" avro:"first_name"`
-	LastName   string          `json:"last_name" xml:"last_name" avro:"last_name"`
-	Gender     string          `json:"gender" xml:"gender" avro:"gender"`
-	SSN        string          `json:"ssn" xml:"ssn" avro:"ssn"`
-	Hobby      string          `json:"hobby" xml:"hobby" avro:"hobby"`
-	Job        *JobInfo        `json:"job" xml:"job" avro:"job"`
-	Address    *AddressInfo    `json:"address" xml:"address" avro:"address"`
-	Contact    *ContactInfo    `json:"contact" xml:"contact" avro:"contact"`
-	CreditCard *CreditCardInfo `json:"credit_card" xml:"credit_card" avro:"credit_card"`
+	FirstName  string          `json:"first_name" xml:"first_name" avro:"first_name" parquet:"first_name"`
+	LastName   string          `json:"last_name" xml:"last_name" avro:"last_name" parquet:"last_name"`
+	Gender     string          `json:"gender" xml:"gender" avro:"gender" parquet:"gender"`
+	SSN        string          `json:"ssn" xml:"ssn" avro:"ssn" parquet:"ssn"`
+	Hobby      string          `json:"hobby" xml:"hobby" avro:"hobby" parquet:"hobby"`
+	Job        *JobInfo        `json:"job" xml:"job" avro:"job" parquet:"job"`
+	Address    *AddressInfo    `json:"address" xml:"address" avro:"address" parquet:"address"`
+	Contact    *ContactInfo    `json:"contact" xml:"contact" avro:"contact" parquet:"contact"`
+	CreditCard *CreditCardInfo `json:"credit_card" xml:"credit_card" avro:"credit_card" parquet:"credit_card"`
 }
 
 type CreditCardInfo struct {
-	Type   string `json:"type" xml:"type" avro:"type"`
-	Number string `json:"number" xml:"number" avro:"number"`
-	Exp    string `json:"exp" xml:"exp" avro:"exp"`
-	Cvv    string `json:"cvv" xml:"cvv" avro:"cvv"`
+	Type   string `json:"type" xml:"type" avro:"type" parquet:"type"`
+	Number string `json:"number" xml:"number" avro:"number" parquet:"number"`
+	Exp    string `json:"exp" xml:"exp" avro:"exp" parquet:"exp"`
+	Cvv    string `json:"cvv" xml:"cvv" avro:"cvv" parquet:"cvv"`
 }
 
 // make random person data
@@ -83,9 +83,9 @@ func MakeRandomPersonForProtoBuf() *protobuf.PersonInfo {
 **                                                                   **
 ***********************************************************************/
 type BookInfo struct {
-	Title  string `json:"title" xml:"title" avro:"title"`
-	Author string `json:"author" xml:"author" avro:"author"`
-	Genre  string `json:"genre" xml:"genre" avro:"genre"`
+	Title  string `json:"title" xml:"title" avro:"title" parquet:"title"`
+	Author string `json:"author" xml:"author" avro:"author" parquet:"author"`
+	Genre  string `json:"genre" xml:"genre" avro:"genre" parquet:"genre"`
 }
 
 // make random book data
@@ -111,12 +111,12 @@ func MakeRandomBookForProtoBuf() *protobuf.BookInfo {
 **                                                                   **
 ***********************************************************************/
 type CarInfo struct {
-	Type         string `json:"type" xml:"type" avro:"type"`
-	Fuel         string `json:"fuel" xml:"fuel" avro:"fuel"`
-	Transmission string `json:"transmission" xml:"transmission" avro:"transmission"`
-	Brand        string `json:"brand" xml:"brand" avro:"brand"`
-	Model        string `json:"model" xml:"model" avro:"model"`
-	Year         int    `json:"year" xml:"year" avro:"year"`
+	Type         string `json:"type" xml:"type" avro:"type" parquet:"type"`
+	Fuel         string `json:"fuel" xml:"fuel" avro:"fuel" parquet:"fuel"`
+	Transmission string `json:"transmission" xml:"transmission" avro:"transmission" parquet:"transmission"`
+	Brand        string `json:"brand" xml:"brand" avro:"brand" parquet:"brand"`
+	Model        string `json:"model" xml:"model" avro:"model" parquet:"model"`
+	Year         int    `json:"year" xml:"year" avro:"year" parquet:"year"`
 }
 
 // make random car data
@@ -145,14 +145,14 @@ func MakeRandomCarForProtoBuf() *protobuf.CarInfo {
 **                                                                   **
 ***********************************************************************/
 type AddressInfo struct {
-	Address   string  `json:"address" xml:"address" avro:"address"`
-	Street    string  `json:"street" xml:"street" avro:"street"`
-	City      string  `json:"city" xml:"city" avro:"city"`
-	State     string  `json:"state" xml:"state" avro:"state"`
-	Zip       string  `json:"zip" xml:"zip" avro:"zip"`
-	Country   string  `json:"country" xml:"country" avro:"country"`
-	Latitude  float64 `json:"latitude" xml:"latitude" avro:"latitude"`
-	Longitude float64 `json:"longitude" xml:"longitude" avro:"longitude"`
+	Address   string  `json:"address" xml:"address" avro:"address" parquet:"address"`
+	Street    string  `json:"street" xml:"street" avro:"street" parquet:"street"`
+	City      string  `json:"city" xml:"city" avro:"city" parquet:"city"`
+	State     string  `json:"state" xml:"state" avro:"state" parquet:"state"`
+	Zip       string  `json:"zip" xml:"zip" avro:"zip" parquet:"zip"`
+	Country   string  `json:"country" xml:"country" avro:"country" parquet:"country"`
+	Latitude  float64 `json:"latitude" xml:"latitude" avro:"latitude" parquet:"latitude"`
+	Longitude float64 `json:"longitude" xml:"longitude" avro:"longitude" parquet:"longitude"`
 }
 
 // make random address data
@@ -183,8 +183,8 @@ func MakeRandomAddressForProtoBuf() *protobuf.AddressInfo {
 **                                                                   **
 ***********************************************************************/
 type ContactInfo struct {
-	Phone string `json:"phone" xml:"phone" avro:"phone"`
-	Email string `json:"email" xml:"email" avro:"email"`
+	Phone string `json:"phone" xml:"phone" avro:"phone" parquet:"phone"`
+	Email string `json:"email" xml:"email" avro:"email" parquet:"email"`
 }
 
 // make random book data
@@ -209,8 +209,8 @@ func MakeRandomContactForProtoBuf() *protobuf.ContactInfo {
 **                                                                   **
 ***********************************************************************/
 type MovieInfo struct {
-	Name  string `json:"name" xml:"name" avro:"name"`
-	Genre string `json:"genre" xml:"genre" avro:"genre"`
+	Name  string `json:"name" xml:"name" avro:"name" parquet:"name"`
+	Genre string `json:"genre" xml:"genre" avro:"genre" parquet:"genre"`
 }
 
 // make random job data
@@ -235,10 +235,10 @@ func MakeRandomMovieForProtoBuf() *protobuf.MovieInfo {
 **                                                                   **
 ***********************************************************************/
 type JobInfo struct {
-	Company    string `json:"company" xml:"company" avro:"company"`
-	Title      string `json:"title" xml:"title" avro:"title"`
-	Descriptor string `json:"descriptor" xml:"descriptor" avro:"descriptor"`
-	Level      string `json:"level" xml:"level" avro:"level"`
+	Company    string `json:"company" xml:"company" avro:"company" parquet:"company"`
+	Title      string `json:"title" xml:"title" avro:"title" parquet:"title"`
+	Descriptor string `json:"descriptor" xml:"descriptor" avro:"descriptor" parquet:"descriptor"`
+	Level      string `json:"level" xml:"level" avro:"level" parquet:"level"`
 }
 
 // make random job data
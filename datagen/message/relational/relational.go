@@ -0,0 +1,125 @@
+package relational
+
+import (
+	"math"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+/**********************************************************************
+**                                                                   **
+**                  Entity structs for relational                    **
+**                                                                   **
+***********************************************************************/
+// The entities reference their parents by id. Every entity is produced with
+// its id as the key, so child topics join on the id fields and parent topics
+// can be read as tables. created_at is in epoch milliseconds.
+
+type Customer struct {
+	CustomerID string `json:"customer_id" avro:"customer_id" parquet:"customer_id"`
+	FirstName  string `json:"first_name" avro:"first_name" parquet:"first_name"`
+	LastName   string `json:"last_name" avro:"last_name" parquet:"last_name"`
+	Email      string `json:"email" avro:"email" parquet:"email"`
+	Country    string `json:"country" avro:"country" parquet:"country"`
+	CreatedAt  int64  `json:"created_at" avro:"created_at" parquet:"created_at"`
+}
+
+type Product struct {
+	ProductID string  `json:"product_id" avro:"product_id" parquet:"product_id"`
+	Name      string  `json:"name" avro:"name" parquet:"name"`
+	Category  string  `json:"category" avro:"category" parquet:"category"`
+	Price     float64 `json:"price" avro:"price" parquet:"price"`
+	CreatedAt int64   `json:"created_at" avro:"created_at" parquet:"created_at"`
+}
+
+type Order struct {
+	OrderID    string `json:"order_id" avro:"order_id" parquet:"order_id"`
+	CustomerID string `json:"customer_id" avro:"customer_id" parquet:"customer_id"`
+	Status     string `json:"status" avro:"status" parquet:"status"`
+	CreatedAt  int64  `json:"created_at" avro:"created_at" parquet:"created_at"`
+}
+
+type OrderItem struct {
+	OrderItemID string  `json:"order_item_id" avro:"order_item_id" parquet:"order_item_id"`
+	OrderID     string  `json:"order_id" avro:"order_id" parquet:"order_id"`
+	ProductID   string  `json:"product_id" avro:"product_id" parquet:"product_id"`
+	Quantity    int     `json:"quantity" avro:"quantity" parquet:"quantity"`
+	UnitPrice   float64 `json:"unit_price" avro:"unit_price" parquet:"unit_price"`
+	CreatedAt   int64   `json:"created_at" avro:"created_at" parquet:"created_at"`
+}
+
+type Payment struct {
+	PaymentID string  `json:"payment_id" avro:"payment_id" parquet:"payment_id"`
+	OrderID   string  `json:"order_id" avro:"order_id" parquet:"order_id"`
+	Amount    float64 `json:"amount" avro:"amount" parquet:"amount"`
+	Method    string  `json:"method" avro:"method" parquet:"method"`
+	CreatedAt int64   `json:"created_at" avro:"created_at" parquet:"created_at"`
+}
+
+/**********************************************************************
+**                                                                   **
+**                        Make random entities                       **
+**                                                                   **
+***********************************************************************/
+func MakeRandomCustomer() Customer {
+	p := gofakeit.Person()
+	return Customer{
+		CustomerID: gofakeit.UUID(),
+		FirstName:  p.FirstName,
+		LastName:   p.LastName,
+		Email:      p.Contact.Email,
+		Country:    p.Address.Country,
+		CreatedAt:  now(),
+	}
+}
+
+func MakeRandomProduct() Product {
+	return Product{
+		ProductID: gofakeit.UUID(),
+		Name:      gofakeit.ProductName(),
+		Category:  gofakeit.ProductCategory(),
+		Price:     gofakeit.Price(1, 500),
+		CreatedAt: now(),
+	}
+}
+
+func MakeRandomOrder(customerID string) Order {
+	return Order{
+		OrderID:    gofakeit.UUID(),
+		CustomerID: customerID,
+		Status:     "created",
+		CreatedAt:  now(),
+	}
+}
+
+func MakeRandomOrderItem(orderID string, product Product) OrderItem {
+	return OrderItem{
+		OrderItemID: gofakeit.UUID(),
+		OrderID:     orderID,
+		ProductID:   product.ProductID,
+		Quantity:    gofakeit.Number(1, 5),
+		UnitPrice:   product.Price,
+		CreatedAt:   now(),
+	}
+}
+
+// MakeRandomPayment pays amount, the total of the items of the order.
+func MakeRandomPayment(orderID string, amount float64) Payment {
+	return Payment{
+		PaymentID: gofakeit.UUID(),
+		OrderID:   orderID,
+		Amount:    math.Round(amount*100) / 100,
+		Method:    gofakeit.RandomString([]string{"credit_card", "debit_card", "paypal", "bank_transfer", "gift_card"}),
+		CreatedAt: now(),
+	}
+}
+
+// Total returns the price of the item.
+func (i OrderItem) Total() float64 {
+	return float64(i.Quantity) * i.UnitPrice
+}
+
+func now() int64 {
+	return time.Now().UnixMilli()
+}
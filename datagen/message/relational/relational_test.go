@@ -0,0 +1,27 @@
+package relational
+
+import "testing"
+
+func TestMakeRandomEntitiesReferenceParents(t *testing.T) {
+	customer := MakeRandomCustomer()
+	product := MakeRandomProduct()
+	order := MakeRandomOrder(customer.CustomerID)
+	item := MakeRandomOrderItem(order.OrderID, product)
+	payment := MakeRandomPayment(order.OrderID, item.Total()+0.004)
+
+	switch {
+	case customer.CustomerID == "" || product.ProductID == "" || order.OrderID == "" || item.OrderItemID == "" || payment.PaymentID == "":
+		t.Errorf("empty id: %+v %+v %+v %+v %+v", customer, product, order, item, payment)
+	case order.CustomerID != customer.CustomerID:
+		t.Errorf("order customer %s, want %s", order.CustomerID, customer.CustomerID)
+	case item.OrderID != order.OrderID || item.ProductID != product.ProductID:
+		t.Errorf("item order %s product %s, want %s %s", item.OrderID, item.ProductID, order.OrderID, product.ProductID)
+	case item.UnitPrice != product.Price || item.Total() != float64(item.Quantity)*product.Price:
+		t.Errorf("item %+v of product price %v", item, product.Price)
+	case payment.OrderID != order.OrderID:
+		t.Errorf("payment order %s, want %s", payment.OrderID, order.OrderID)
+	}
+	if payment.Amount != MakeRandomPayment(order.OrderID, item.Total()).Amount {
+		t.Errorf("payment amount %v not rounded to cents of %v", payment.Amount, item.Total())
+	}
+}
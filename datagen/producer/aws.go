@@ -0,0 +1,83 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/aws"
+)
+
+/**********************************************************************
+**                                                                   **
+**                         AWS_MSK_IAM support                       **
+**                                                                   **
+***********************************************************************/
+// awsMechanism returns an AWS_MSK_IAM mechanism that signs every
+// (re)authentication with current credentials. Without static keys the default
+// credential chain is used: environment, shared profile, web identity (IRSA),
+// container and instance roles. The credentials cache refreshes them before
+// they expire, including the assumed role.
+func awsMechanism(cp config.ProducerConfig) (sasl.Mechanism, error) {
+	ctx := context.Background()
+
+	loadOpts := []func(*awsconfig.LoadOptions) error{}
+	if cp.Sasl.AwsRegion != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(cp.Sasl.AwsRegion))
+	}
+	if cp.Sasl.AwsProfile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(cp.Sasl.AwsProfile))
+	}
+	if cp.Sasl.AwsAccessKeyId != "" {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cp.Sasl.AwsAccessKeyId, cp.Sasl.AwsSecretAccessKey, cp.Sasl.AwsSessionToken)))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := awsCfg.Credentials
+	if cp.Sasl.AwsRoleArn != "" {
+		provider = awssdk.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cp.Sasl.AwsRoleArn,
+			func(o *stscreds.AssumeRoleOptions) {
+				if cp.Sasl.AwsRoleSessionName != "" {
+					o.RoleSessionName = cp.Sasl.AwsRoleSessionName
+				}
+				if cp.Sasl.AwsExternalId != "" {
+					o.ExternalID = awssdk.String(cp.Sasl.AwsExternalId)
+				}
+			}))
+		logger.Log.Info(fmt.Sprintln("assume role : ", cp.Sasl.AwsRoleArn))
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("no aws credentials found")
+	}
+
+	// get the first credentials now so a bad config fails at startup
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info(fmt.Sprintln("aws credentials source : ", creds.Source))
+
+	return aws.ManagedStreamingIAM(func(ctx context.Context) (aws.Auth, error) {
+		creds, err := provider.Retrieve(ctx)
+		if err != nil {
+			return aws.Auth{}, fmt.Errorf("failed to refresh aws credentials : %w", err)
+		}
+		return aws.Auth{
+			AccessKey:    creds.AccessKeyID,
+			SecretKey:    creds.SecretAccessKey,
+			SessionToken: creds.SessionToken,
+			UserAgent:    cp.Sasl.AwsUserAgent, // franz-go default when empty
+		}, nil
+	}), nil
+}
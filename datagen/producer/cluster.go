@@ -0,0 +1,232 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                          Cluster targets                          **
+**                                                                   **
+***********************************************************************/
+// target is a cluster the workers produce to, with the options of its clients.
+type target struct {
+	name    string
+	opts    []kgo.Opt
+	metrics *clusterMetrics // nil without clusters
+}
+
+// buildTargets builds the client options of every cluster config produces to.
+func buildTargets(config *config.ConfigConfig) ([]target, error) {
+	var targets []target
+	for _, t := range config.Targets() {
+		opts, err := clientOpts(config, t)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", t.Name, err)
+		}
+		tg := target{name: t.Name, opts: opts}
+		if len(config.Clusters) > 0 {
+			tg.metrics = registerClusterMetrics(t.Name)
+		}
+		targets = append(targets, tg)
+	}
+	return targets, nil
+}
+
+// named wraps err with the cluster name when there are several targets.
+func (t target) named(targets []target, err error) error {
+	if err == nil || len(targets) < 2 {
+		return err
+	}
+	return fmt.Errorf("cluster %s: %w", t.name, err)
+}
+
+// checkTopics runs checkTopic on every target.
+func checkTopics(ctx context.Context, targets []target, ct config.TopicConfig, startup bool) error {
+	for _, t := range targets {
+		if len(targets) > 1 {
+			logger.Log.Info(fmt.Sprintln("checking topic on cluster : ", t.name))
+		}
+		if err := checkTopic(ctx, t.opts, ct, startup); err != nil {
+			return t.named(targets, err)
+		}
+	}
+	return nil
+}
+
+// deleteTopics deletes the topic on every target, logging every failure.
+func deleteTopics(ctx context.Context, targets []target, topic string) {
+	for _, t := range targets {
+		if err := deleteTopic(ctx, t.opts, topic); err != nil {
+			logger.Log.Error(fmt.Sprintln(t.named(targets, err)))
+		}
+	}
+}
+
+/**********************************************************************
+**                                                                   **
+**                           Target client                           **
+**                                                                   **
+***********************************************************************/
+// targetClient produces every record to the client of each target, so every
+// cluster receives the same stream. Transactions are begun and ended on every
+// cluster but are not atomic across clusters. It is used by a single worker.
+type targetClient struct {
+	sequencer
+	clients []*clusterClient
+}
+
+type clusterClient struct {
+	target
+	client *kgo.Client
+	inTxn  bool
+}
+
+// newTargetClient creates a client per target with the shared options and extra.
+func newTargetClient(targets []target, extra []kgo.Opt, worker int) (*targetClient, error) {
+	tc := &targetClient{sequencer: sequencer{worker: worker}}
+	for _, t := range targets {
+		opts := append(t.opts[:len(t.opts):len(t.opts)], extra...) // the shared options are never appended in place
+		client, err := kgo.NewClient(opts...)
+		if err != nil {
+			tc.Close()
+			return nil, t.named(targets, err)
+		}
+		tc.clients = append(tc.clients, &clusterClient{target: t, client: client})
+	}
+	return tc, nil
+}
+
+func (tc *targetClient) Close() {
+	for _, c := range tc.clients {
+		c.client.Close()
+	}
+}
+
+// Produce produces rec to every cluster. promise is called once per cluster.
+func (tc *targetClient) Produce(ctx context.Context, rec *kgo.Record, promise func(*kgo.Record, error)) {
+	tc.stamp(rec)
+	if len(tc.clients) == 1 {
+		tc.clients[0].produce(ctx, rec, promise, false)
+		return
+	}
+
+	// every cluster gets its own copy of the same record, made before any is produced
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now()
+	}
+	records := make([]*kgo.Record, len(tc.clients))
+	for i := range tc.clients {
+		copied := *rec
+		records[i] = &copied
+	}
+	for i, c := range tc.clients {
+		c.produce(ctx, records[i], promise, true)
+	}
+}
+
+func (c *clusterClient) produce(ctx context.Context, rec *kgo.Record, promise func(*kgo.Record, error), named bool) {
+	start := time.Now()
+	c.client.Produce(ctx, rec, func(r *kgo.Record, err error) {
+		c.metrics.observe(r, time.Since(start), err)
+		if err != nil && named {
+			err = fmt.Errorf("cluster %s: %w", c.name, err)
+		}
+		promise(r, err)
+	})
+}
+
+// Flush flushes every cluster and returns the first error.
+func (tc *targetClient) Flush(ctx context.Context) error {
+	var first error
+	for _, c := range tc.clients {
+		if err := c.client.Flush(ctx); err != nil && first == nil {
+			first = tc.named(c, err)
+		}
+	}
+	return first
+}
+
+// AbortBufferedRecords drops the records not yet sent to any cluster.
+func (tc *targetClient) AbortBufferedRecords(ctx context.Context) error {
+	var first error
+	for _, c := range tc.clients {
+		if err := c.client.AbortBufferedRecords(ctx); err != nil && first == nil {
+			first = tc.named(c, err)
+		}
+	}
+	return first
+}
+
+// BeginTransaction begins a transaction on every cluster that has none open.
+func (tc *targetClient) BeginTransaction() error {
+	for _, c := range tc.clients {
+		if c.inTxn {
+			continue
+		}
+		if err := c.client.BeginTransaction(); err != nil {
+			return tc.named(c, err)
+		}
+		c.inTxn = true
+	}
+	return nil
+}
+
+// EndTransaction ends the open transaction of every cluster and returns the first error.
+func (tc *targetClient) EndTransaction(ctx context.Context, commit kgo.TransactionEndTry) error {
+	var first error
+	for _, c := range tc.clients {
+		if !c.inTxn {
+			continue
+		}
+		c.inTxn = false
+		if err := c.client.EndTransaction(ctx, commit); err != nil && first == nil {
+			first = tc.named(c, err)
+		}
+	}
+	return first
+}
+
+// EndAndBeginTransaction ends the open transaction of every cluster, then calls
+// onEnd once with the first end error. Like kgo, the next transaction is begun on
+// every cluster unless onEnd returns an error, which is then returned.
+func (tc *targetClient) EndAndBeginTransaction(ctx context.Context, how kgo.EndBeginTxnHow, commit kgo.TransactionEndTry, onEnd func(context.Context, error) error) error {
+	var endErr error
+	for _, c := range tc.clients {
+		if !c.inTxn {
+			continue
+		}
+		err := c.client.EndAndBeginTransaction(ctx, how, commit, func(_ context.Context, err error) error {
+			return err
+		})
+		c.inTxn = err == nil
+		if err != nil && endErr == nil {
+			endErr = tc.named(c, err)
+		}
+	}
+	if err := onEnd(ctx, endErr); err != nil {
+		return err
+	}
+	// clusters whose end failed join the next transaction
+	return tc.BeginTransaction()
+}
+
+// ForceMetadataRefresh makes every client pick up new partitions.
+func (tc *targetClient) ForceMetadataRefresh() {
+	for _, c := range tc.clients {
+		c.client.ForceMetadataRefresh()
+	}
+}
+
+func (tc *targetClient) named(c *clusterClient, err error) error {
+	if len(tc.clients) < 2 {
+		return err
+	}
+	return fmt.Errorf("cluster %s: %w", c.name, err)
+}
@@ -0,0 +1,207 @@
+package producer
+
+import (
+	"fmt"
+	"math"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/control"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"time"
+)
+
+/**********************************************************************
+**                                                                   **
+**                      Control API of generator                     **
+**                                                                   **
+***********************************************************************/
+// burstState is a temporary speed-up that is undone once it ends.
+type burstState struct {
+	factor float64
+	until  time.Time
+	base   *config.ConfigConfig // config restored after the burst
+}
+
+var _ control.Generator = (*generator)(nil)
+
+func (g *generator) Status() control.Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ds := g.producer.Load()
+	status := control.Status{
+		State:       "running",
+		ProduceMode: ds.Produce.Mode,
+		Jitter:      ds.Jitter,
+		MessageMode: ds.Message.Mode,
+		Workers:     []control.WorkerStatus{},
+	}
+	switch ds.Produce.Mode {
+	case value.PRODUCE_MODE_INTERVAL:
+		status.Interval = ds.Produce.Interval.String()
+	case value.PRODUCE_MODE_RATE_PER_SEC:
+		status.RatePerSecond = ds.Produce.RatePerSecond
+	case value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS:
+		status.DataRateLimitBPS = ds.Produce.LimitDataAmountPerSecond
+	}
+	if ds.Message.Mode == value.MESSAGE_MODE_QUICKSTART {
+		status.MessageMode += " " + ds.Message.Quickstart
+	}
+	if g.burst != nil {
+		status.Burst = &control.BurstStatus{
+			Factor:    g.burst.factor,
+			Remaining: time.Until(g.burst.until).Round(time.Second).String(),
+		}
+	}
+
+	paused := len(g.workers) > 0
+	for _, w := range g.workers {
+		ws := control.WorkerStatus{Index: w.index, Paused: w.paused.Load()}
+		if ds.Transaction.Enabled {
+			ws.TransactionalID = workerTransactionalId(ds.Transaction.Id, w.index)
+		}
+		paused = paused && ws.Paused
+		status.Workers = append(status.Workers, ws)
+	}
+	switch {
+	case g.stopping:
+		status.State = "stopping"
+	case paused:
+		status.State = "paused"
+	}
+	return status
+}
+
+// Pause stops producing on one worker, or on every worker when index is 0.
+// Each worker ends its open transaction first.
+func (g *generator) Pause(index int) error {
+	return g.setPaused(index, true)
+}
+
+// Resume starts producing again on one worker, or on every worker when index is 0.
+func (g *generator) Resume(index int) error {
+	return g.setPaused(index, false)
+}
+
+func (g *generator) setPaused(index int, paused bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		return control.ErrStopping
+	}
+	if index == 0 {
+		g.paused = paused
+		for _, w := range g.workers {
+			w.paused.Store(paused)
+			w.signal()
+		}
+	} else {
+		if index > len(g.workers) {
+			return fmt.Errorf("%w %d, %d workers are running", control.ErrUnknownWorker, index, len(g.workers))
+		}
+		w := g.workers[index-1]
+		w.paused.Store(paused)
+		w.signal()
+	}
+	logger.Log.Info(fmt.Sprintf("control api : paused=%t worker=%d", paused, index))
+	return nil
+}
+
+// Set changes config values by YAML path. Only values that apply to the
+// running workers can be changed; connection settings belong in the config file.
+func (g *generator) Set(values map[string]string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		return control.ErrStopping
+	}
+	next, err := g.config.With(values, "control api")
+	if err != nil {
+		return err
+	}
+	if config.ChangedUnder(config.Diff(g.config, next), clientKeys...) {
+		return fmt.Errorf("connection settings (%v) need new clients, change them in the config file", clientKeys)
+	}
+	if err := g.apply(next); err != nil {
+		return err
+	}
+	// a running burst is restored onto the new values
+	if g.burst != nil {
+		g.burst.base, _ = g.burst.base.With(values, "control api")
+	}
+	return nil
+}
+
+// Burst multiplies the produce rate by factor for duration, then restores it.
+// Interval mode divides the interval instead.
+func (g *generator) Burst(factor float64, duration time.Duration) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		return control.ErrStopping
+	}
+	if g.burst != nil {
+		return control.ErrBurstRunning
+	}
+
+	base := g.config
+	produce := base.Datagen.Produce
+	values := map[string]string{}
+	switch produce.Mode {
+	case value.PRODUCE_MODE_INTERVAL:
+		values["datagen.produce.interval"] = time.Duration(float64(produce.Interval) / factor).String()
+	case value.PRODUCE_MODE_RATE_PER_SEC:
+		values["datagen.produce.rate-per-second"] = strconv.FormatFloat(math.Ceil(float64(produce.RatePerSecond)*factor), 'f', 0, 64)
+	case value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS:
+		values["datagen.produce.data-rate-limit-bps"] = strconv.FormatFloat(math.Ceil(float64(produce.DataRateLimitBPS)*factor), 'f', 0, 64)
+	}
+	next, err := base.With(values, "burst")
+	if err != nil {
+		return err
+	}
+	if err := g.apply(next); err != nil {
+		return err
+	}
+
+	burst := &burstState{factor: factor, until: time.Now().Add(duration), base: base}
+	g.burst = burst
+	logger.Log.Info(fmt.Sprintf("control api : burst x%g for %s", factor, duration))
+
+	time.AfterFunc(duration, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		// replaced by a config file change or stopped in the meantime
+		if g.burst != burst || g.stopping {
+			return
+		}
+		g.burst = nil
+		if err := g.apply(burst.base); err != nil {
+			logger.Log.Error(fmt.Sprintln("burst end failed : ", err))
+			return
+		}
+		logger.Log.Info("control api : burst ended")
+	})
+	return nil
+}
+
+// Stop ends every worker, each committing its open transaction and flushing,
+// and then lets Datagen return. The workers are waited for without holding
+// g.mu, so status requests are answered while they end.
+func (g *generator) Stop() {
+	g.mu.Lock()
+	if g.stopping {
+		g.mu.Unlock()
+		return
+	}
+	g.stopping = true
+	logger.Log.Info("stopping")
+	if g.expand != nil {
+		g.expand.Stop()
+	}
+	workers := g.grow(0)
+	g.mu.Unlock()
+
+	waitWorkers(workers)
+	close(g.stopped)
+}
@@ -0,0 +1,156 @@
+package producer
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/control"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDryRunGenerator starts a generator writing to a file sink.
+func newDryRunGenerator(t *testing.T) (*generator, string) {
+	t.Helper()
+	dir := t.TempDir()
+	sinkPath := filepath.Join(dir, "records.jsonl")
+	configPath := filepath.Join(dir, "datagen.yaml")
+	yaml := `bootstrap-server: localhost:9092
+topic:
+  name: datagen
+sink:
+  type: file
+  path: ` + sinkPath + `
+datagen:
+  go-routine: 2
+  produce:
+    mode: rate-per-second
+    rate-per-second: 1000
+  message:
+    mode: quickstart
+    quickstart: user
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(configPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator(context.Background())
+	if err := g.start(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return g, sinkPath
+}
+
+func TestControlAPI(t *testing.T) {
+	g, sinkPath := newDryRunGenerator(t)
+	srv := httptest.NewServer(control.Handler("token", g))
+	defer srv.Close()
+	client := control.NewClient(srv.URL, "token")
+
+	run := func(command string, args ...string) *control.Status {
+		t.Helper()
+		status, err := client.Run(command, args)
+		if err != nil {
+			t.Fatalf("%s %v: %v", command, args, err)
+		}
+		return status
+	}
+	fails := func(want string, command string, args ...string) {
+		t.Helper()
+		if _, err := client.Run(command, args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s %v: error = %v, want %q", command, args, err, want)
+		}
+	}
+	paused := func(status *control.Status) []bool {
+		var p []bool
+		for _, w := range status.Workers {
+			p = append(p, w.Paused)
+		}
+		return p
+	}
+
+	if status := run("status"); status.State != "running" || len(status.Workers) != 2 || status.RatePerSecond != 1000 {
+		t.Fatalf("status %+v, want 2 running workers at 1000 records per second", status)
+	}
+	if _, err := control.NewClient(srv.URL, "other").Run("status", nil); err == nil {
+		t.Errorf("status with a wrong token: no error")
+	}
+
+	// pause and resume
+	if status := run("pause"); status.State != "paused" || !equalBools(paused(status), []bool{true, true}) {
+		t.Errorf("pause: status %+v", status)
+	}
+	if status := run("resume", "2"); status.State != "running" || !equalBools(paused(status), []bool{true, false}) {
+		t.Errorf("resume 2: status %+v", status)
+	}
+	fails("unknown worker", "pause", "3")
+	if status := run("resume"); !equalBools(paused(status), []bool{false, false}) {
+		t.Errorf("resume: status %+v", status)
+	}
+
+	// set
+	if status := run("set", "rate=500", "workers=3"); status.RatePerSecond != 500 || len(status.Workers) != 3 {
+		t.Errorf("set rate=500 workers=3: status %+v", status)
+	}
+	fails("connection settings", "set", "bootstrap-server=other:9092")
+	fails("datagen.jitter", "set", "jitter=2")
+
+	// burst
+	if status := run("burst", "2", "1h"); status.Burst == nil || status.Burst.Factor != 2 || status.RatePerSecond != 1000 {
+		t.Errorf("burst 2 1h: status %+v", status)
+	}
+	fails("already running", "burst", "3", "1m")
+
+	// stop answers while a worker is still ending
+	g.mu.Lock()
+	ending := &worker{index: len(g.workers) + 1, reload: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	g.workers = append(g.workers, ending)
+	g.mu.Unlock()
+
+	if status := run("stop"); status.State != "stopping" {
+		t.Errorf("stop: status %+v", status)
+	}
+	<-ending.stop
+	if status := run("status"); status.State != "stopping" {
+		t.Errorf("status while stopping: %+v", status)
+	}
+	fails("stopping", "set", "rate=10")
+	select {
+	case <-g.stopped:
+		t.Fatalf("stopped before every worker ended")
+	default:
+	}
+	close(ending.done)
+	select {
+	case <-g.stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("not stopped after every worker ended")
+	}
+	g.output.Close()
+
+	records, err := os.ReadFile(sinkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Errorf("no records written to the sink")
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/control"
 	"spitha/datagen/datagen/logger"
 	"spitha/datagen/datagen/message"
-	"spitha/datagen/datagen/message/avro"
-	"spitha/datagen/datagen/message/protobuf"
 	"spitha/datagen/datagen/value"
 	"strings"
-	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kgo"
@@ -24,7 +24,7 @@ type datagenProducer struct {
 	Jitter  float64
 	Produce struct {
 		Mode                     string
-		Interval                 int
+		Interval                 time.Duration
 		RatePerSecond            int
 		LimitDataAmountPerSecond int
 	}
@@ -32,16 +32,23 @@ type datagenProducer struct {
 		Mode         string
 		Quickstart   string
 		MessageBytes int
+		Source       messageSource // replay and mirror, nil otherwise
 	}
 	SchemaRegistry struct {
 		MessageType string
-		Serde       sr.Serde
+		Serde       *sr.Serde
 	}
 	Transaction struct {
-		Enabled bool
-		Id      string
+		Enabled     bool
+		Id          string
+		Timeout     time.Duration
+		MaxRecords  int64
+		MaxDuration time.Duration
+		AbortRate   float64
+		Verifier    *txnVerifier
 	}
-	SRMessageType string
+	SRMessageType  string
+	SequenceHeader bool
 }
 
 /**********************************************************************
@@ -55,64 +62,117 @@ func Datagen(config *config.ConfigConfig) {
 	ticker := time.NewTicker(1 * time.Second)
 	go metricTicker(ticker)
 
-	ctx := context.Background()
+	g := newGenerator(context.Background())
+	if err := g.start(config); err != nil {
+		logger.Log.Error(fmt.Sprintln(err))
+		panic(err)
+	}
+
+	// apply config file changes to the running workers
+	watchConfig(config, g)
+
+	// control api
+	if config.Control.Listen != "" {
+		if err := control.Serve(config.Control.Listen, config.Control.Token, g); err != nil {
+			logger.Log.Error(fmt.Sprintln(err))
+			panic(err)
+		}
+	}
+
+	// workers run until stopped through the control api or by a signal
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		g.Stop()
+	}()
+	<-g.stopped
+	logger.Log.Info("datagen stopped")
+
+	// topic lifecycle
+	g.mu.Lock()
+	last, targets, output := g.config, g.targets, g.output
+	g.mu.Unlock()
+	if source := g.producer.Load().Message.Source; source != nil {
+		source.Close()
+	}
+	g.producer.Load().Transaction.Verifier.Close()
+	if err := output.Close(); err != nil {
+		logger.Log.Error(fmt.Sprintln(err))
+	}
+	if last.Topic.DeleteAfterRun && output == nil {
+		for _, ct := range last.Topics() {
+			deleteTopics(context.Background(), targets, ct.Name)
+		}
+	}
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Producer options                         **
+**                                                                   **
+***********************************************************************/
+// clientOpts builds the client options of target shared by every worker. A
+// change to any of these settings requires new clients.
+func clientOpts(config *config.ConfigConfig, target config.Target) ([]kgo.Opt, error) {
 	opts := []kgo.Opt{}
 
 	/*******************************
 	**  Producer - Bootstrap server
 	********************************/
-	bootstrapServer := config.BootstrapServer
-	if bootstrapServer == "" {
-		panic("please input producer.bootstrap.servers settings")
-	}
+	// config values are checked by config.Validate before Datagen is called
+	bootstrapServer := target.BootstrapServer
 	opts = append(opts, kgo.SeedBrokers(strings.Split(bootstrapServer, ",")...))
 	opts = append(opts, kgo.WithLogger(kzap.New(logger.Log))) // log
 
 	/*******************************
 	**   Producer - Kafka Auth
 	********************************/
-	opts, err := auth(opts, config.Producer)
+	opts, err := auth(opts, target.Producer)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		panic(err)
+		return nil, err
 	}
 
 	/*******************************
 	**   Producer - Client ID
 	********************************/
 	// proudcer client id
-	if config.Producer.ClientId != "" {
-		opts = append(opts, kgo.ClientID(config.Producer.ClientId))
-		logger.Log.Info(fmt.Sprintln("cleint id : ", config.Producer.ClientId))
+	if target.Producer.ClientId != "" {
+		opts = append(opts, kgo.ClientID(target.Producer.ClientId))
+		logger.Log.Info(fmt.Sprintln("cleint id : ", target.Producer.ClientId))
 	}
 
 	/*******************************
-	**   Producer - Transactional ID
+	**   Producer - Acks, Idempotence
 	********************************/
-	// proudcer transactional id
-	if config.Producer.TransactionalID == "" {
-		opts = append(opts, kgo.DisableIdempotentWrite())  // idempotence = disable
-		opts = append(opts, kgo.RequiredAcks(kgo.NoAck())) // ack = 0
+	opts, err = delivery(opts, target.Producer)
+	if err != nil {
+		return nil, err
 	}
 
 	/*******************************
 	**   Producer - Message
 	********************************/
 	// proudcer max message bytes
-	if config.Producer.MaxMessageBytes != "" {
-		opts = append(opts, kgo.ProducerBatchMaxBytes(int32(stringToInt(config.Producer.MaxMessageBytes))))
+	if target.Producer.MaxMessageBytes > 0 {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(int32(target.Producer.MaxMessageBytes)))
 	}
 
 	// proudcer lingers
-	if config.Producer.Lingers != "" {
-		opts = append(opts, kgo.ProducerLinger(time.Duration(stringToInt(config.Producer.Lingers))))
+	if target.Producer.Lingers > 0 {
+		opts = append(opts, kgo.ProducerLinger(target.Producer.Lingers.Duration()))
+	}
+
+	// buffer sized for the message bytes at startup; later changes keep it
+	if config.Datagen.Message.Mode == value.MESSAGE_MODE_MESSAGE_BYTES {
+		opts = append(opts, kgo.MaxBufferedRecords(250<<20/config.Datagen.Message.MessageBytes.Int()+1))
 	}
 
 	/*******************************
 	**   Producer - Compression type
 	********************************/
-	if config.Producer.CompressionType != "" {
-		switch config.Producer.CompressionType {
+	if target.Producer.CompressionType != "" {
+		switch target.Producer.CompressionType {
 		case "uncompressed":
 			opts = append(opts, kgo.ProducerBatchCompression(kgo.NoCompression()))
 		case "zstd":
@@ -130,58 +190,53 @@ func Datagen(config *config.ConfigConfig) {
 	**   Producer - Topic Name
 	********************************/
 	// setting for topic
-	topic := config.Topic.Name
-	if topic == "" {
-		panic("please input topic settings")
-	}
-	opts = append(opts, kgo.DefaultProduceTopic(topic))
-
-	/*******************************
-	**   Datagen - Go routine
-	********************************/
-	// datagen work thread
-	workThread := 1
-	if config.Datagen.GoRoutine != "" {
-		workThread = stringToInt(config.Datagen.GoRoutine)
-	}
+	opts = append(opts, kgo.DefaultProduceTopic(config.Topic.Name))
+	return opts, nil
+}
 
+/**********************************************************************
+**                                                                   **
+**                         Producer settings                         **
+**                                                                   **
+***********************************************************************/
+// newDatagenProducer builds the settings the workers read. They can change on
+// reload without new clients.
+func newDatagenProducer(config *config.ConfigConfig) *datagenProducer {
 	/*******************************
 	**   Datagen - Produce mode
 	********************************/
-	var dp datagenProducer
+	dp := &datagenProducer{}
 
 	// datagen produce mode
-	switch config.Datagen.Proudce.Mode {
+	switch config.Datagen.Produce.Mode {
 	case "interval":
 		dp.Produce.Mode = value.PRODUCE_MODE_INTERVAL
-		if config.Datagen.Proudce.Interval != "" {
-			dp.Produce.Interval = stringToInt(config.Datagen.Proudce.Interval)
-		} else {
-			dp.Produce.Interval = 100
-		}
+		dp.Produce.Interval = config.Datagen.Produce.Interval.Duration()
 	case "rate-per-second":
 		dp.Produce.Mode = value.PRODUCE_MODE_RATE_PER_SEC
-		if config.Datagen.Proudce.RatePerSecond != "" {
-			dp.Produce.RatePerSecond = stringToInt(config.Datagen.Proudce.RatePerSecond)
-		} else {
-			dp.Produce.RatePerSecond = 100
-		}
+		dp.Produce.RatePerSecond = config.Datagen.Produce.RatePerSecond.Int()
 	case "data-rate-limit-bps":
 		dp.Produce.Mode = value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS
-		if config.Datagen.Proudce.DataRateLimitBPS != "" {
-			dp.Produce.LimitDataAmountPerSecond = stringToInt(config.Datagen.Proudce.DataRateLimitBPS)
-		} else {
-			dp.Produce.LimitDataAmountPerSecond = 100
-		}
+		dp.Produce.LimitDataAmountPerSecond = config.Datagen.Produce.DataRateLimitBPS.Int()
 	}
 
 	/*******************************
 	**   Datagen - Transaction Producer
 	********************************/
-	// datagen work thread
-	if config.Producer.TransactionalID != "" {
+	// datagen work thread, a dry run has no transactions
+	if config.Producer.TransactionalID != "" && !config.Sink.DryRun() {
 		dp.Transaction.Enabled = true
 		dp.Transaction.Id = config.Producer.TransactionalID
+
+		// transaction timeout
+		dp.Transaction.Timeout = config.Datagen.Transaction.Timeout.Duration()
+
+		// transaction size by record count or time
+		dp.Transaction.MaxRecords = config.Datagen.Transaction.Records
+		dp.Transaction.MaxDuration = config.Datagen.Transaction.Duration.Duration()
+
+		// deliberate abort probability
+		dp.Transaction.AbortRate = config.Datagen.Transaction.AbortRate
 	} else {
 		dp.Transaction.Enabled = false
 	}
@@ -193,128 +248,40 @@ func Datagen(config *config.ConfigConfig) {
 	case value.MESSAGE_MODE_QUICKSTART:
 		dp.Message.Mode = value.MESSAGE_MODE_QUICKSTART
 		dp.Message.Quickstart = config.Datagen.Message.QuickStart
-		if config.Datagen.Message.QuickStart != value.QUICKSTART_USER &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_BOOK &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_CAR &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_CONTACT &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_JOB &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_MOVIE &&
-			config.Datagen.Message.QuickStart != value.QUICKSTART_ADDRESS {
-			panic("The quickstart option is limited to the following options: user, book, car, address, contact, movie, and job.")
-		}
 	case value.MESSAGE_MODE_MESSAGE_BYTES:
 		dp.Message.Mode = value.MESSAGE_MODE_MESSAGE_BYTES
-		if config.Datagen.Message.MessageBytes != "" {
-			dp.Message.MessageBytes = stringToInt(config.Datagen.Message.MessageBytes)
-		} else {
-			dp.Message.MessageBytes = 100
-		}
-		opts = append(opts, kgo.MaxBufferedRecords(250<<20/dp.Message.MessageBytes+1))
+		dp.Message.MessageBytes = config.Datagen.Message.MessageBytes.Int()
+	case value.MESSAGE_MODE_REPLAY:
+		dp.Message.Mode = value.MESSAGE_MODE_REPLAY // the file is opened by the generator
+	case value.MESSAGE_MODE_MIRROR:
+		dp.Message.Mode = value.MESSAGE_MODE_MIRROR // the topic is consumed by the generator
+	case value.MESSAGE_MODE_RELATIONAL:
+		dp.Message.Mode = value.MESSAGE_MODE_RELATIONAL // the entities are made by the generator
 	}
 
 	/*******************************
 	**   Datagen - Jitter
 	********************************/
 	// datagen jitter setting
-	dp.Jitter = 0.0
-	if config.Datagen.Jitter != "" {
-		dp.Jitter = stringToFloat64(config.Datagen.Jitter)
-	}
-
-	/*******************************
-	**   Admin - Check Topic
-	********************************/
-	err = checkTopic(ctx, opts, config.Topic)
-	if err != nil {
-		panic(err)
-	}
-
-	/*******************************
-	**   Schema registry
-	********************************/
-	if config.Producer.SchemaRegistry.Server.Urls != "" && config.Datagen.Message.QuickStart != "" { // only quickstart
-		dp.SRMessageType = config.Producer.SchemaRegistry.Type
-		logger.Log.Info("use schema registry")
-		srOpts := []sr.ClientOpt{}
-		srOpts = append(srOpts, sr.URLs(config.Producer.SchemaRegistry.Server.Urls))
-
-		// basic auth
-		if config.Producer.SchemaRegistry.Server.Username != "" && config.Producer.SchemaRegistry.Server.Password != "" {
-			srOpts = append(srOpts, sr.BasicAuth(config.Producer.SchemaRegistry.Server.Username, config.Producer.SchemaRegistry.Server.Password))
-		}
-
-		// create client
-		srClient, err := sr.NewClient(srOpts...)
-		if err != nil {
-			panic(err)
-		}
-
-		switch config.Producer.SchemaRegistry.Type {
-		case "avro":
-			avro.HelperAvro(dp.Message.Quickstart, srClient, &dp.SchemaRegistry.Serde, config.Producer.SchemaRegistry.Subject)
-		case "protobuf":
-			protobuf.HelperProtobuf(dp.Message.Quickstart, srClient, &dp.SchemaRegistry.Serde, config.Producer.SchemaRegistry.Subject)
-		default:
-			logger.Log.Info("only the (avro, protobuf) type is supported.")
-			os.Exit(1)
-		}
-	}
-
-	/*******************************
-	**   Producer - Go routine
-	********************************/
-	var wg sync.WaitGroup
-	wg.Add(workThread)
-
-	for i := 1; i <= workThread; i++ {
-		go dp.worker(i, opts, &wg, ctx)
-	}
+	dp.Jitter = config.Datagen.Jitter
+	dp.SequenceHeader = config.Datagen.SequenceHeader
+	return dp
+}
 
-	wg.Wait()
+// messageSource hands out the records of a message mode that does not generate
+// them, shared by every worker.
+type messageSource interface {
+	next(stop <-chan struct{}) *kgo.Record // nil once stop is closed, the source ended or a record could not be made
+	Close()
 }
 
-/**********************************************************************
-**                                                                   **
-**                        Producer go routine                        **
-**                                                                   **
-***********************************************************************/
-func (ds *datagenProducer) worker(index int, opts []kgo.Opt, wg *sync.WaitGroup, ctx context.Context) {
-
-	// Producer Transaction
-	if ds.Transaction.Enabled {
-		transactionId := fmt.Sprintf("%s-%d", ds.Transaction.Id, index)
-		opts = append(opts, kgo.TransactionalID(transactionId))
-		opts = append(opts, kgo.TransactionTimeout(5*time.Second))
-		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
-		logger.Log.Info(fmt.Sprintln("transactional id : ", transactionId))
+// makeRecord builds the next record of the message mode. A replay or mirror waits
+// until its next record is due and returns nil once stop is closed.
+func (ds *datagenProducer) makeRecord(stop <-chan struct{}) *kgo.Record {
+	if ds.Message.Source != nil {
+		return ds.Message.Source.next(stop)
 	}
-
-	// Producer Client
-	producerClient, err := kgo.NewClient(opts...)
-	if err != nil {
-		panic(err)
-	}
-	defer producerClient.Close()
-
-	// Produce Messages
-	// for range jobs {
-	switch ds.Produce.Mode {
-	case value.PRODUCE_MODE_INTERVAL:
-		ds.produceInterval(producerClient, ctx)
-	case value.PRODUCE_MODE_RATE_PER_SEC:
-		ds.produceRatePerSecond(producerClient, ctx)
-	case value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS:
-		ds.produceLimitPerSecond(producerClient, ctx)
-	default:
-		logger.Log.Info(fmt.Sprintln("the value is missing or invalid in the produce type"))
-	}
-	// }
-	// Flush
-	if err := producerClient.Flush(ctx); err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		panic(err)
-	}
-	wg.Done()
+	return message.MakeMessage(ds.SchemaRegistry.Serde, ds.Message.Mode, ds.Message.Quickstart, ds.Message.MessageBytes, ds.SRMessageType)
 }
 
 /**********************************************************************
@@ -322,24 +289,43 @@ func (ds *datagenProducer) worker(index int, opts []kgo.Opt, wg *sync.WaitGroup,
 **                         Interval Producer                         **
 **                                                                   **
 ***********************************************************************/
-func (ds *datagenProducer) produceInterval(client *kgo.Client, ctx context.Context) {
+func (ds *datagenProducer) produceInterval(client recordSink, ctx context.Context, ts *txnState, stop <-chan struct{}) {
+	// Tracks whether we're currently inside a transaction
+	inTxn := false
+
+	// Use an atomic flag to signal whether we must abort the transaction
+	var needAbort atomic.Bool
+
 	for {
+		// Settings changed or the worker stops: finish the open transaction first
+		if stopped(stop) {
+			if inTxn {
+				endTxn(client, ctx, ts, &needAbort)
+			}
+			return
+		}
+
 		// Begin a new transaction if enabled
-		if ds.Transaction.Enabled {
+		if ds.Transaction.Enabled && !inTxn {
 			if err := client.BeginTransaction(); err != nil {
-				logger.Log.Error(fmt.Sprintln(err))
+				logger.Log.Error(fmt.Sprintf("begin txn: %q", err))
+				// Back off a bit to avoid log storms / tight loops
+				time.Sleep(25 * time.Millisecond)
 				continue
 			}
+			inTxn = true
+			ts.begin()
 		}
 
 		// Compute jittered sleep interval for pacing
-		jitterInterval := message.MakeRatePerSecondJitter(ds.Produce.Mode, ds.Produce.Interval, ds.Jitter)
+		jitterInterval := time.Duration(message.MakeRatePerSecondJitter(ds.Produce.Mode, int(ds.Produce.Interval), ds.Jitter))
 
 		// Build a record (avoid naming the var "message" to prevent confusion with the package)
-		rec := message.MakeMessage(&ds.SchemaRegistry.Serde, ds.Message.Mode, ds.Message.Quickstart, ds.Message.MessageBytes, ds.SRMessageType)
-
-		// Use an atomic flag to signal whether we must abort the transaction
-		var needAbort atomic.Bool
+		rec := ds.makeRecord(stop)
+		if rec == nil {
+			continue // stopped, or no record was made
+		}
+		ts.stamp(rec)
 
 		// latency measurement
 		latencyStart := time.Now()
@@ -359,31 +345,14 @@ func (ds *datagenProducer) produceInterval(client *kgo.Client, ctx context.Conte
 		elapsed := time.Since(latencyStart)
 		checkElapsedLatency(elapsed)
 
-		if ds.Transaction.Enabled {
-			// Wait for all in-flight sends + callbacks to finish.
-			// Without Flush, some records may still be buffered and not part of this transaction.
-			if err := client.Flush(ctx); err != nil {
-				// If Flush fails, prefer the abort path.
-				needAbort.Store(true)
-				logger.Log.Error(fmt.Sprintln(err))
-			}
-
-			if needAbort.Load() {
-				// Remove any not-yet-sent records so they don't leak into the next transaction
-				_ = client.AbortBufferedRecords(ctx)
-				// Abort the current transaction
-				_ = client.EndTransaction(ctx, kgo.TryAbort)
-			} else {
-				// Try to commit; if it fails with an abortable state, immediately TryAbort
-				if err := client.EndTransaction(ctx, kgo.TryCommit); err != nil {
-					logger.Log.Error(fmt.Sprintln(err))
-					_ = client.EndTransaction(ctx, kgo.TryAbort)
-				}
-			}
+		// End the transaction once it reached its configured size
+		if ds.Transaction.Enabled && ts.due() {
+			endTxn(client, ctx, ts, &needAbort)
+			inTxn = false
 		}
 
 		// Sleep after the transaction is finalized (commit/abort)
-		time.Sleep(time.Duration(jitterInterval) * time.Millisecond)
+		sleepUnlessStopped(jitterInterval, stop)
 	}
 }
 
@@ -392,7 +361,7 @@ func (ds *datagenProducer) produceInterval(client *kgo.Client, ctx context.Conte
 **                   Produce Message per Second                      **
 **                                                                   **
 ***********************************************************************/
-func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.Context) {
+func (ds *datagenProducer) produceRatePerSecond(client recordSink, ctx context.Context, ts *txnState, stop <-chan struct{}) {
 	// Per-second pacing window
 	windowStart := time.Now()
 
@@ -421,6 +390,7 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 			return false
 		}
 		inTxn = true
+		ts.begin()
 		return true
 	}
 
@@ -428,16 +398,28 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 	_ = ensureTxn()
 
 	sentThisWindow := 0
-	var needAbort atomic.Bool // Set by callbacks on any produce error (window-scoped)
+	var needAbort atomic.Bool // Set by callbacks on any produce error (transaction-scoped)
 
 	for {
+		// 0) Settings changed or the worker stops: finish the open transaction first
+		if stopped(stop) {
+			if inTxn {
+				endTxn(client, ctx, ts, &needAbort)
+			}
+			return
+		}
+
 		// 1) Never produce unless we're definitely in a transaction when enabled
 		if !ensureTxn() {
 			continue
 		}
 
 		// 2) Build one record
-		rec := message.MakeMessage(&ds.SchemaRegistry.Serde, ds.Message.Mode, ds.Message.Quickstart, ds.Message.MessageBytes, ds.SRMessageType)
+		rec := ds.makeRecord(stop)
+		if rec == nil {
+			continue // stopped, or no record was made
+		}
+		ts.stamp(rec)
 
 		// 3) Async produce; DO NOT end/commit/abort inside the callback.
 		start := time.Now()
@@ -451,35 +433,24 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 		checkElapsedLatency(time.Since(start))
 		sentThisWindow++
 
-		// 4) Window boundary: reached target RPS for this second
+		// 4) Sized transactions end as soon as they are full, independent of the window
+		if ds.Transaction.Enabled && inTxn && ts.due() {
+			inTxn = endAndBeginTxn(client, ctx, ts, needAbort.Load()) == nil
+			needAbort.Store(false)
+		}
+
+		// 5) Window boundary: reached target RPS for this second
 		if sentThisWindow >= rps {
 			// Finish pacing for this 1s window
 			if rem := time.Second - time.Since(windowStart); rem > 0 {
-				time.Sleep(rem)
+				sleepUnlessStopped(rem, stop)
 			}
 
-			// 5) End the transaction for this window (commit if clean, else abort),
+			// 6) End the transaction for this window (commit if clean, else abort),
 			//    then (if successful) begin the next transaction right away.
-			if ds.Transaction.Enabled && inTxn {
-				endTry := kgo.TryCommit
-				if needAbort.Load() {
-					endTry = kgo.TryAbort
-				}
-
-				// End → (maybe) Begin in one call; EndAndBeginTransaction performs a Flush internally.
-				err := client.EndAndBeginTransaction(
-					ctx,
-					kgo.EndBeginTxnSafe, // Safe mode: blocks new produces until end completes
-					endTry,              // Commit or Abort based on the window status
-					func(ctx context.Context, endErr error) error {
-						if endErr != nil {
-							// If ending failed, return the error so Begin is skipped.
-							logger.Log.Error(fmt.Sprintf("end txn err: %q", endErr))
-							return endErr
-						}
-						return nil // Proceed to BeginTransaction internally
-					},
-				)
+			if ds.Transaction.Enabled && inTxn && !ts.sized() {
+				err := endAndBeginTxn(client, ctx, ts, needAbort.Load())
+				needAbort.Store(false)
 
 				if err != nil {
 					// We failed to end-and-begin; mark ourselves out of a txn.
@@ -493,6 +464,7 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 						logger.Log.Error(fmt.Sprintf("begin txn after error: %q", berr))
 					} else {
 						inTxn = true
+						ts.begin()
 					}
 				} else {
 					// Success: we are already inside the next transaction
@@ -500,10 +472,9 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 				}
 			}
 
-			// 6) Start the next window
+			// 7) Start the next window
 			windowStart = time.Now()
 			sentThisWindow = 0
-			needAbort.Store(false)
 
 			// Recompute RPS with jitter for the new window
 			rps = message.MakeRatePerSecondJitter(value.PRODUCE_MODE_RATE_PER_SEC, ds.Produce.RatePerSecond, ds.Jitter)
@@ -519,7 +490,7 @@ func (ds *datagenProducer) produceRatePerSecond(client *kgo.Client, ctx context.
 **                    Produce Limit Per Second                       **
 **                                                                   **
 ***********************************************************************/
-func (ds *datagenProducer) produceLimitPerSecond(client *kgo.Client, ctx context.Context) {
+func (ds *datagenProducer) produceLimitPerSecond(client recordSink, ctx context.Context, ts *txnState, stop <-chan struct{}) {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
@@ -550,6 +521,7 @@ func (ds *datagenProducer) produceLimitPerSecond(client *kgo.Client, ctx context
 			return false
 		}
 		inTxn = true
+		ts.begin()
 		return true
 	}
 
@@ -558,38 +530,23 @@ func (ds *datagenProducer) produceLimitPerSecond(client *kgo.Client, ctx context
 
 	for {
 		select {
+		// ----- settings changed or the worker stops -----
+		case <-stop:
+			if inTxn {
+				endTxn(client, ctx, ts, &needAbort)
+			}
+			return
+
 		// ----- window boundary: once per second -----
 		case <-ticker.C:
-			if ds.Transaction.Enabled && inTxn && bytesSent > 0 {
+			if ds.Transaction.Enabled && inTxn && bytesSent > 0 && !ts.sized() {
 				// Decide commit vs abort based on callback errors in this window.
-				endTry := kgo.TryCommit
-				if needAbort.Load() {
-					endTry = kgo.TryAbort
-				}
-
-				// End → (maybe) Begin in one call; includes Flush internally.
-				err := client.EndAndBeginTransaction(
-					ctx,
-					kgo.EndBeginTxnSafe,
-					endTry,
-					func(ctx context.Context, endErr error) error {
-						if endErr != nil {
-							logger.Log.Error(fmt.Sprintf("end txn err: %q", endErr))
-							// Return non-nil to skip Begin; we’ll re-enter with ensureTxn() next loop.
-							return endErr
-						}
-						return nil
-					},
-				)
-				if err != nil {
-					inTxn = false // not in a txn now; next send will re-open
-				} else {
-					inTxn = true // new txn already started for the next window
-				}
+				// On error Begin is skipped; we'll re-enter with ensureTxn() next loop.
+				inTxn = endAndBeginTxn(client, ctx, ts, needAbort.Load()) == nil
+				needAbort.Store(false)
 			}
 			// Reset window counters/state
 			bytesSent = 0
-			needAbort.Store(false)
 
 			// Recompute jittered limit for the next second
 			limitBps = message.MakeRatePerSecondJitter(value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS, ds.Produce.LimitDataAmountPerSecond, ds.Jitter)
@@ -598,6 +555,12 @@ func (ds *datagenProducer) produceLimitPerSecond(client *kgo.Client, ctx context
 			}
 
 		default:
+			// Sized transactions end as soon as they are full, independent of the window.
+			if ds.Transaction.Enabled && inTxn && ts.due() {
+				inTxn = endAndBeginTxn(client, ctx, ts, needAbort.Load()) == nil
+				needAbort.Store(false)
+			}
+
 			// If we already hit the byte budget, briefly yield until the next tick.
 			if bytesSent >= limitBps {
 				time.Sleep(300 * time.Microsecond)
@@ -610,7 +573,11 @@ func (ds *datagenProducer) produceLimitPerSecond(client *kgo.Client, ctx context
 			}
 
 			// Build one record (avoid variable name "message" to not shadow the package)
-			rec := message.MakeMessage(&ds.SchemaRegistry.Serde, ds.Message.Mode, ds.Message.Quickstart, ds.Message.MessageBytes, ds.SRMessageType)
+			rec := ds.makeRecord(stop)
+			if rec == nil {
+				continue // stopped, or no record was made
+			}
+			ts.stamp(rec)
 
 			// Async produce; never end/commit/abort a txn inside this callback.
 			start := time.Now()
@@ -0,0 +1,86 @@
+package producer
+
+import (
+	"fmt"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                    Acks, Idempotence, Retries                     **
+**                                                                   **
+***********************************************************************/
+func delivery(opts []kgo.Opt, cp config.ProducerConfig) ([]kgo.Opt, error) {
+	transactional := cp.TransactionalID != ""
+
+	/**
+	 * Acks
+	 */
+	// acks=0 is kept as the default for non-transactional producers
+	acks := "0"
+	if transactional {
+		acks = "all"
+	}
+	if cp.Acks != "" {
+		acks = cp.Acks
+	}
+	switch acks {
+	case "0":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	case "1":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case "all", "-1":
+		acks = "all"
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	default:
+		return nil, fmt.Errorf("producer.acks must be one of 0, 1, all: %q", cp.Acks)
+	}
+	if transactional && acks != "all" {
+		return nil, fmt.Errorf("producer.acks must be all with a transactional-id")
+	}
+
+	/**
+	 * Idempotence
+	 */
+	// idempotence is enabled by default only when every in-sync replica acks
+	idempotence := acks == "all"
+	if cp.EnableIdempotence != nil {
+		idempotence = *cp.EnableIdempotence
+	}
+	if idempotence && acks != "all" {
+		return nil, fmt.Errorf("producer.enable-idempotence requires producer.acks=all")
+	}
+	if !idempotence {
+		if transactional {
+			return nil, fmt.Errorf("producer.enable-idempotence cannot be disabled with a transactional-id")
+		}
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+	logger.Log.Info(fmt.Sprintf("acks : %s, idempotence : %t", acks, idempotence))
+
+	/**
+	 * In flight, Retries, Timeouts, Buffer
+	 */
+	if cp.MaxInFlight > 0 {
+		if idempotence {
+			logger.Log.Info("producer.max-in-flight has no effect with idempotence enabled")
+		}
+		opts = append(opts, kgo.MaxProduceRequestsInflightPerBroker(cp.MaxInFlight))
+	}
+	if cp.RecordRetries != nil {
+		opts = append(opts, kgo.RecordRetries(*cp.RecordRetries))
+	}
+	if cp.DeliveryTimeout > 0 {
+		opts = append(opts, kgo.RecordDeliveryTimeout(cp.DeliveryTimeout.Duration()))
+	}
+	if cp.RequestTimeout > 0 {
+		opts = append(opts, kgo.ProduceRequestTimeout(cp.RequestTimeout.Duration()))
+	}
+	if cp.MaxBufferedBytes > 0 {
+		opts = append(opts, kgo.MaxBufferedBytes(cp.MaxBufferedBytes.Int()))
+	}
+	return opts, nil
+}
@@ -0,0 +1,477 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                             Generator                             **
+**                                                                   **
+***********************************************************************/
+// generator owns the running workers and applies config reloads to them.
+// Workers read the current datagenProducer each time they (re)enter a produce
+// loop; the client options are only replaced when a reload needs new clients.
+type generator struct {
+	ctx      context.Context
+	producer atomic.Pointer[datagenProducer]
+
+	mu       sync.Mutex // guards the fields below
+	config   *config.ConfigConfig
+	targets  []target
+	output   *recordWriter // dry-run sink, nil when producing to Kafka
+	workers  []*worker     // workers[i] runs as worker i+1
+	paused   bool          // new workers start paused
+	burst    *burstState   // running burst, see control.go
+	stopping bool
+	stopped  chan struct{} // closed once Stop ended every worker
+	expand   *time.Timer   // pending topic.expand
+}
+
+// worker is a single producer go routine.
+type worker struct {
+	index  int
+	reload chan struct{} // signalled when the producer settings changed
+	stop   chan struct{} // closed to end the worker
+	done   chan struct{} // closed once the worker ended
+	paused atomic.Bool   // the worker waits for the next signal instead of producing
+	client atomic.Pointer[targetClient]
+}
+
+// WORKER_STOP_TIMEOUT bounds the time a stopped worker takes to end its
+// transaction and flush its buffered records.
+const WORKER_STOP_TIMEOUT = 10 * time.Second
+
+// clientKeys are the config keys that need new clients when they change.
+var clientKeys = []string{"bootstrap-server", "clusters", "producer", "topic", "datagen.targets", "datagen.transaction.timeout", "sink"}
+
+func newGenerator(ctx context.Context) *generator {
+	return &generator{ctx: ctx, stopped: make(chan struct{})}
+}
+
+// start checks the topic on every cluster and starts the configured number of workers.
+func (g *generator) start(cfg *config.ConfigConfig) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	targets, output, err := g.connect(cfg, true)
+	if err != nil {
+		return err
+	}
+
+	dp := newDatagenProducer(cfg)
+	if err := g.startVerifier(cfg, targets, dp); err != nil {
+		output.Close()
+		return err
+	}
+	if err := registerSchema(cfg, dp); err != nil {
+		dp.Transaction.Verifier.Close()
+		output.Close()
+		return err
+	}
+	if err := g.openSource(cfg, dp); err != nil {
+		dp.Transaction.Verifier.Close()
+		output.Close()
+		return err
+	}
+
+	g.config, g.targets, g.output = cfg, targets, output
+	g.producer.Store(dp)
+	g.scale(cfg.Datagen.GoRoutine)
+
+	if expand := cfg.Topic.Expand; expand.Partitions > 0 && output == nil {
+		g.expand = time.AfterFunc(expand.After.Duration(), func() {
+			for _, ct := range cfg.Topics() {
+				g.expandTopic(ct.Name, expand.Partitions)
+			}
+		})
+	}
+	return nil
+}
+
+// reload applies a changed config file.
+func (g *generator) reload(next *config.ConfigConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		return
+	}
+	if err := g.apply(next); err != nil {
+		logger.Log.Error(fmt.Sprintln("config reload failed, keeping the running config : ", err))
+		return
+	}
+	// a file change replaces the settings of a running burst
+	g.burst = nil
+}
+
+// apply switches to next, which must be valid. Produce mode, rate, jitter, message and
+// transaction sizing are picked up by the running workers; the worker count is
+// scaled up or down; connection, auth, topic and transaction timeout changes
+// replace every worker with one using a new client.
+// g.mu must be held.
+func (g *generator) apply(next *config.ConfigConfig) error {
+	changed := config.Diff(g.config, next)
+	if len(changed) == 0 {
+		return nil
+	}
+	prev := g.producer.Load()
+	rebuild := config.ChangedUnder(changed, clientKeys...)
+
+	targets, output := g.targets, g.output
+	// a new dry-run sink is closed and new cluster metrics dropped unless it is applied
+	applied := false
+	defer func() {
+		if !applied && output != g.output {
+			output.Close()
+		}
+		if !applied && rebuild {
+			retainClusterMetrics(g.targets)
+		}
+	}()
+	if rebuild {
+		var err error
+		if targets, output, err = g.connect(next, false); err != nil {
+			return err
+		}
+	}
+
+	dp := newDatagenProducer(next)
+
+	// the verifier keeps running across reloads; it is started once it is first needed
+	dp.Transaction.Verifier = prev.Transaction.Verifier
+	for _, key := range []string{"datagen.transaction.verify-grace-period", "control", "topic.expand", "topic.truncate-before-run"} {
+		if config.ChangedUnder(changed, key) {
+			logger.Log.Info(fmt.Sprintln(key, " takes effect after a restart"))
+		}
+	}
+	if !next.Datagen.Transaction.Verify {
+		dp.Transaction.Verifier = nil
+	} else if err := g.startVerifier(next, targets, dp); err != nil {
+		return err
+	}
+	defer func() {
+		if !applied && dp.Transaction.Verifier != prev.Transaction.Verifier {
+			dp.Transaction.Verifier.Close()
+		}
+	}()
+
+	// the schema only needs registering again when the registry or message changed
+	if rebuild || config.ChangedUnder(changed, "datagen.message") {
+		if err := registerSchema(next, dp); err != nil {
+			return err
+		}
+	} else {
+		dp.SchemaRegistry.Serde = prev.SchemaRegistry.Serde
+		dp.SRMessageType = prev.SRMessageType
+	}
+
+	// a changed message setting starts the replay, mirror or relational entities over
+	reopen := config.ChangedUnder(changed, "datagen.message")
+	switch dp.Message.Mode {
+	case value.MESSAGE_MODE_MIRROR:
+		reopen = reopen || rebuild // the source cluster may have changed
+	case value.MESSAGE_MODE_RELATIONAL:
+		reopen = reopen || config.ChangedUnder(changed, "topic.name")
+	}
+	if reopen {
+		if err := g.openSource(next, dp); err != nil {
+			return err
+		}
+	} else {
+		dp.Message.Source = prev.Message.Source
+	}
+	defer func() {
+		if !applied && dp.Message.Source != nil && dp.Message.Source != prev.Message.Source {
+			dp.Message.Source.Close()
+		}
+	}()
+
+	prevOutput := g.output
+	g.config, g.targets, g.output = next, targets, output
+	g.producer.Store(dp)
+	applied = true
+	if rebuild {
+		logger.Log.Info("connection settings changed, replacing the producer clients")
+		retainClusterMetrics(targets)
+		g.scale(0)
+		if err := prevOutput.Close(); err != nil {
+			logger.Log.Error(fmt.Sprintln(err))
+		}
+	} else {
+		for _, w := range g.workers {
+			w.signal()
+		}
+	}
+	g.scale(next.Datagen.GoRoutine)
+	if prev.Message.Source != nil && prev.Message.Source != dp.Message.Source {
+		prev.Message.Source.Close() // workers still waiting on it return once signalled
+	}
+	if prev.Transaction.Verifier != dp.Transaction.Verifier {
+		prev.Transaction.Verifier.Close()
+	}
+	logger.Log.Info(fmt.Sprintf("config applied : %s", strings.Join(changed, ", ")))
+	return nil
+}
+
+// connect checks the topic on every cluster the workers produce to, or opens the
+// sink of a dry run, which never connects to Kafka.
+func (g *generator) connect(cfg *config.ConfigConfig, startup bool) ([]target, *recordWriter, error) {
+	if cfg.Sink.DryRun() {
+		output, err := openRecordWriter(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Log.Info(fmt.Sprintln("dry run, records are written to the sink : ", cfg.Sink.Type))
+		return nil, output, nil
+	}
+
+	targets, err := buildTargets(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	/*******************************
+	**   Admin - Check Topic
+	********************************/
+	for _, ct := range cfg.Topics() {
+		if err := checkTopics(g.ctx, targets, ct, startup); err != nil {
+			return nil, nil, err
+		}
+	}
+	return targets, nil, nil
+}
+
+// openSource opens the file of the replay message mode, starts consuming the
+// topic of the mirror message mode or starts the entities of the relational
+// message mode. Once a replay ended with at-eof stop, datagen stops.
+func (g *generator) openSource(cfg *config.ConfigConfig, dp *datagenProducer) error {
+	switch dp.Message.Mode {
+	case value.MESSAGE_MODE_REPLAY:
+		replay, err := openReplay(cfg.Datagen.Message.Replay, g.Stop)
+		if err != nil {
+			return err
+		}
+		dp.Message.Source = replay
+	case value.MESSAGE_MODE_MIRROR:
+		mirror, err := startMirror(g.ctx, cfg)
+		if err != nil {
+			return err
+		}
+		dp.Message.Source = mirror
+	case value.MESSAGE_MODE_RELATIONAL:
+		dp.Message.Source = newRelationalSource(cfg)
+	}
+	return nil
+}
+
+// startVerifier starts the transaction verifier if dp needs one and none runs yet.
+// It reads the only cluster, validation rejects verify with more than one.
+func (g *generator) startVerifier(cfg *config.ConfigConfig, targets []target, dp *datagenProducer) error {
+	if !dp.Transaction.Enabled || !cfg.Datagen.Transaction.Verify || dp.Transaction.Verifier != nil {
+		return nil
+	}
+	verifier, err := startTxnVerifier(g.ctx, targets[0].opts, cfg.Topic.Name, cfg.Datagen.Transaction.VerifyGracePeriod.Duration())
+	if err != nil {
+		return err
+	}
+	dp.Transaction.Verifier = verifier
+	return nil
+}
+
+// scale starts or stops workers until n are running. Workers are stopped from
+// the highest index down and scale waits until they ended.
+func (g *generator) scale(n int) {
+	waitWorkers(g.grow(n))
+}
+
+// grow starts workers until n are running, or stops the workers above n and
+// returns them so the caller can wait until they ended.
+func (g *generator) grow(n int) []*worker {
+	for len(g.workers) < n {
+		w := &worker{
+			index:  len(g.workers) + 1,
+			reload: make(chan struct{}, 1),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		w.paused.Store(g.paused)
+		g.workers = append(g.workers, w)
+		go g.run(w, g.targets, g.output)
+	}
+	if len(g.workers) <= n {
+		return nil
+	}
+	stopping := g.workers[n:]
+	g.workers = g.workers[:n]
+	for _, w := range stopping {
+		close(w.stop)
+	}
+	return stopping
+}
+
+// waitWorkers waits until every stopped worker ended, at most
+// WORKER_STOP_TIMEOUT after being stopped.
+func waitWorkers(workers []*worker) {
+	for _, w := range workers {
+		<-w.done
+	}
+}
+
+// watchConfig applies every change of the config file to the generator.
+func watchConfig(current *config.ConfigConfig, g *generator) {
+	config.Watch(current, func(next *config.ConfigConfig, _ []string) {
+		g.reload(next)
+	})
+}
+
+/**********************************************************************
+**                                                                   **
+**                        Producer go routine                        **
+**                                                                   **
+***********************************************************************/
+func (g *generator) run(w *worker, targets []target, output *recordWriter) {
+	defer close(w.done)
+	ds := g.producer.Load()
+
+	// a stopped worker ends its transaction and flushes within WORKER_STOP_TIMEOUT,
+	// even when the broker is unreachable
+	ctx, cancel := context.WithCancel(g.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+		case <-ctx.Done():
+			return
+		}
+		timer := time.NewTimer(WORKER_STOP_TIMEOUT)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			logger.Log.Error(fmt.Sprintf("worker %d did not end within %s, dropping its buffered records", w.index, WORKER_STOP_TIMEOUT))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Producer Transaction
+	var ts *txnState
+	var txnOpts []kgo.Opt
+	if ds.Transaction.Enabled {
+		transactionId := workerTransactionalId(ds.Transaction.Id, w.index)
+		ts = newTxnState(transactionId)
+		txnOpts = []kgo.Opt{
+			kgo.TransactionalID(transactionId),
+			kgo.TransactionTimeout(ds.Transaction.Timeout),
+			kgo.RequiredAcks(kgo.AllISRAcks()),
+		}
+		logger.Log.Info(fmt.Sprintln("transactional id : ", transactionId))
+	}
+
+	// Producer Client, one per cluster, or the dry-run sink
+	var producerClient recordSink
+	if output != nil {
+		producerClient = newWriterSink(output, w.index)
+	} else {
+		tc, err := newTargetClient(targets, txnOpts, w.index)
+		if err != nil {
+			logger.Log.Error(fmt.Sprintln(err))
+			return
+		}
+		w.client.Store(tc)
+		producerClient = tc
+	}
+	defer producerClient.Close()
+
+	for {
+		// leave is closed on the next reload or stop signal
+		leave := make(chan struct{})
+		go func() {
+			select {
+			case <-w.reload:
+			case <-w.stop:
+			}
+			close(leave)
+		}()
+
+		// Produce Messages
+		ts.configure(ds)
+		producerClient.setSequence(ds.SequenceHeader)
+		switch ds.Produce.Mode {
+		case value.PRODUCE_MODE_INTERVAL:
+			ds.produceInterval(producerClient, ctx, ts, leave)
+		case value.PRODUCE_MODE_RATE_PER_SEC:
+			ds.produceRatePerSecond(producerClient, ctx, ts, leave)
+		case value.PRODUCE_MODE_DATA_RATE_LIMIT_BPS:
+			ds.produceLimitPerSecond(producerClient, ctx, ts, leave)
+		default:
+			logger.Log.Info(fmt.Sprintln("the value is missing or invalid in the produce type"))
+		}
+		<-leave
+
+		// paused workers wait for the next signal
+		for !stopped(w.stop) && w.paused.Load() {
+			select {
+			case <-w.reload:
+			case <-w.stop:
+			}
+		}
+
+		if stopped(w.stop) {
+			// Flush
+			if err := producerClient.Flush(ctx); err != nil {
+				logger.Log.Error(fmt.Sprintln(err))
+			}
+			return
+		}
+		ds = g.producer.Load()
+	}
+}
+
+// signal asks the worker to re-read the producer settings.
+func (w *worker) signal() {
+	select {
+	case w.reload <- struct{}{}:
+	default: // a reload is already pending
+	}
+}
+
+// workerTransactionalId returns the transactional id of the worker with index,
+// so workers on the same transactional id never fence each other.
+func workerTransactionalId(transactionalId string, index int) string {
+	return fmt.Sprintf("%s-%d", transactionalId, index)
+}
+
+/**********************************************************************
+**                                                                   **
+**                            Stop utils                             **
+**                                                                   **
+***********************************************************************/
+// stopped reports whether stop is closed.
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepUnlessStopped sleeps for d or until stop is closed.
+func sleepUnlessStopped(d time.Duration, stop <-chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-stop:
+	}
+}
@@ -0,0 +1,146 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"sync"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	krbConfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/kerberos"
+	"go.uber.org/zap"
+)
+
+/**********************************************************************
+**                                                                   **
+**                         Kerberos support                          **
+**                                                                   **
+***********************************************************************/
+const (
+	DEFAULT_KERBEROS_SERVICE_NAME = "kafka"
+	DEFAULT_KERBEROS_CONFIG_PATH  = "/etc/krb5.conf"
+)
+
+// kerberosLogin keeps the Kerberos client shared by every connection.
+// Keytab and password clients renew their ticket in the background and log in
+// again once it can no longer be renewed. A ticket cache cannot log in again,
+// so it is reloaded whenever the file changes, e.g. after kinit -R or k5start.
+type kerberosLogin struct {
+	cp       config.ProducerConfig
+	krbConf  *krbConfig.Config
+	settings []func(*client.Settings)
+
+	mu          sync.Mutex
+	client      *client.Client
+	ccacheMTime time.Time
+}
+
+// GSSAPI
+func kerberosMechanism(cp config.ProducerConfig) (sasl.Mechanism, error) {
+	configPath := cp.Sasl.KerberosConfig
+	if configPath == "" {
+		configPath = os.Getenv("KRB5_CONFIG")
+	}
+	if configPath == "" {
+		configPath = DEFAULT_KERBEROS_CONFIG_PATH
+	}
+	// Kerberos 클라이언트 구성 생성
+	kbConf, err := krbConfig.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &kerberosLogin{
+		cp:      cp,
+		krbConf: kbConf,
+		settings: []func(*client.Settings){
+			client.DisablePAFXFAST(cp.Sasl.DisablePAFXFAST),
+			client.Logger(zap.NewStdLog(logger.Log)), // renewal errors
+		},
+	}
+	if err := l.login(); err != nil {
+		return nil, err
+	}
+
+	service := cp.Sasl.Servicename
+	if service == "" {
+		service = DEFAULT_KERBEROS_SERVICE_NAME
+	}
+	return kerberos.Kerberos(func(ctx context.Context) (kerberos.Auth, error) {
+		krbClient, err := l.current()
+		if err != nil {
+			return kerberos.Auth{}, err
+		}
+		return kerberos.Auth{
+			Client:           krbClient,
+			Service:          service,
+			PersistAfterAuth: true, // shared by every connection
+		}, nil
+	}), nil
+}
+
+// login creates and logs in a new client.
+func (l *kerberosLogin) login() error {
+	cs := l.cp.Sasl
+	var krbClient *client.Client
+	switch {
+	case cs.KeyTab != "":
+		// Keytab 파일 로드
+		kbTab, err := keytab.Load(cs.KeyTab)
+		if err != nil {
+			return err
+		}
+		krbClient = client.NewWithKeytab(cs.Username, cs.Realm, kbTab, l.krbConf, l.settings...)
+	case cs.Password != "":
+		krbClient = client.NewWithPassword(cs.Username, cs.Realm, cs.Password, l.krbConf, l.settings...)
+	default:
+		info, err := os.Stat(cs.CCache)
+		if err != nil {
+			return err
+		}
+		ccache, err := credentials.LoadCCache(cs.CCache)
+		if err != nil {
+			return err
+		}
+		if krbClient, err = client.NewFromCCache(ccache, l.krbConf, l.settings...); err != nil {
+			return err
+		}
+		l.ccacheMTime = info.ModTime()
+	}
+
+	if err := krbClient.Login(); err != nil {
+		return err
+	}
+	if l.client != nil {
+		l.client.Destroy()
+	}
+	l.client = krbClient
+	return nil
+}
+
+// current returns the client, reloading a changed ticket cache first.
+func (l *kerberosLogin) current() (*client.Client, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cp.Sasl.CCache == "" {
+		return l.client, nil
+	}
+	info, err := os.Stat(l.cp.Sasl.CCache)
+	if err != nil || info.ModTime().Equal(l.ccacheMTime) {
+		// keep the loaded ticket while the file is being replaced
+		return l.client, nil
+	}
+	if err := l.login(); err != nil {
+		logger.Log.Error(fmt.Sprintln("failed to reload Kerberos ticket cache :", err))
+		return l.client, nil
+	}
+	logger.Log.Info(fmt.Sprintln("reloaded Kerberos ticket cache : ", l.cp.Sasl.CCache))
+	return l.client, nil
+}
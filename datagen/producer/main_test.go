@@ -0,0 +1,14 @@
+package producer
+
+import (
+	"os"
+	"spitha/datagen/datagen/logger"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
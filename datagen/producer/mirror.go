@@ -0,0 +1,231 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"sync"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           Mirror source                           **
+**                                                                   **
+***********************************************************************/
+// MIRROR_BUFFER_RECORDS is the number of records the consumer of a mirror reads
+// ahead of the workers. Once it is full the consumer waits, so the topic is
+// consumed at the pace the workers produce.
+const MIRROR_BUFFER_RECORDS = 1000
+
+// consumedRecords counts the records a mirror consumed since the last metric print.
+var consumedRecords atomic.Uint64
+
+// mirrorSource consumes a topic with read_committed isolation and hands every
+// record to the workers amplification times, with the key and fields of the
+// value randomised if configured.
+type mirrorSource struct {
+	cfg     config.MirrorConfig
+	fields  [][]string // randomize-fields split on dots
+	client  *kgo.Client
+	records chan *kgo.Record
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once the consumer ended
+
+	closeOnce sync.Once
+}
+
+// startMirror starts consuming the mirror topic of the source cluster.
+func startMirror(ctx context.Context, cfg *config.ConfigConfig) (*mirrorSource, error) {
+	cm := cfg.Datagen.Message.Mirror
+	source := cfg.MirrorSource()
+	opts, err := clientOpts(cfg, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// the topic must exist, a missing topic would wait forever
+	adminClient, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := kadm.NewClient(adminClient).ListTopics(ctx, cm.Topic)
+	adminClient.Close()
+	if err != nil {
+		return nil, err
+	}
+	if detail, ok := topics[cm.Topic]; !ok || detail.Err != nil {
+		return nil, fmt.Errorf("mirror topic %s not found on cluster %s", cm.Topic, source.Name)
+	}
+
+	// read committed consumer without a group
+	start := kgo.NewOffset().AtStart()
+	if cm.Start == value.MIRROR_START_LATEST {
+		start = kgo.NewOffset().AtEnd()
+	}
+	consumerOpts := append([]kgo.Opt{}, opts...)
+	consumerOpts = append(consumerOpts,
+		kgo.ConsumeTopics(cm.Topic),
+		kgo.ConsumeResetOffset(start),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+	)
+	client, err := kgo.NewClient(consumerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &mirrorSource{
+		cfg:     cm,
+		client:  client,
+		records: make(chan *kgo.Record, MIRROR_BUFFER_RECORDS),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		fields:  cm.Fields(),
+	}
+	go s.consume(ctx)
+	logger.Log.Info(fmt.Sprintf("mirror : topic %s on cluster %s from %s, amplification %d", cm.Topic, source.Name, cm.Start, cm.Amplification))
+	return s, nil
+}
+
+func (s *mirrorSource) consume(ctx context.Context) {
+	defer close(s.done)
+	for {
+		fetches := s.client.PollFetches(ctx)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			logger.Log.Error(fmt.Sprintf("mirror fetch err: topic %s partition %d: %q", topic, partition, err))
+		})
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			consumedRecords.Add(1)
+			if !s.amplify(ctx, iter.Next()) {
+				return
+			}
+		}
+	}
+}
+
+// amplify hands amplification copies of rec to the workers. It returns false
+// once ctx is done.
+func (s *mirrorSource) amplify(ctx context.Context, rec *kgo.Record) bool {
+	for i := 0; i < s.cfg.Amplification; i++ {
+		select {
+		case s.records <- s.copy(rec):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the next record to produce, nil once stop is closed or the mirror closed.
+func (s *mirrorSource) next(stop <-chan struct{}) *kgo.Record {
+	select {
+	case rec := <-s.records:
+		return rec
+	case <-stop:
+	case <-s.done:
+	}
+	return nil
+}
+
+// Close stops consuming, e.g. when a reload replaced the mirror.
+func (s *mirrorSource) Close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.done
+		s.client.Close()
+	})
+}
+
+// copy returns a new record with the key, value and headers of rec. The
+// timestamp is left to the producer.
+func (s *mirrorSource) copy(rec *kgo.Record) *kgo.Record {
+	out := &kgo.Record{
+		Key:     rec.Key,
+		Value:   rec.Value,
+		Headers: append([]kgo.RecordHeader(nil), rec.Headers...),
+	}
+	if s.cfg.RandomizeKey {
+		out.Key = randomizeKey(rec.Key)
+	}
+	if len(s.fields) > 0 {
+		out.Value = randomizeFields(rec.Value, s.fields)
+	}
+	return out
+}
+
+/*******************************
+**   Randomisation
+********************************/
+// randomizeKey adds a random suffix to key. A JSON string key stays a JSON
+// string; a record without a key keeps none.
+func randomizeKey(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+	var s string
+	if len(key) > 1 && key[0] == '"' && json.Unmarshal(key, &s) == nil {
+		out, _ := json.Marshal(s + "-" + randomSuffix())
+		return out
+	}
+	return append(append([]byte{}, key...), "-"+randomSuffix()...)
+}
+
+// randomizeFields replaces the fields of a JSON object value: strings get a
+// random suffix and numbers a random number of the same kind. Values that are
+// not JSON objects and missing fields are left as they are.
+func randomizeFields(v []byte, fields [][]string) []byte {
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return v
+	}
+	for _, path := range fields {
+		randomizeField(obj, path)
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return v
+	}
+	return out
+}
+
+func randomizeField(obj map[string]interface{}, path []string) {
+	for _, name := range path[:len(path)-1] {
+		nested, ok := obj[name].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = nested
+	}
+	name := path[len(path)-1]
+	switch field := obj[name].(type) {
+	case string:
+		obj[name] = field + "-" + randomSuffix()
+	case json.Number:
+		if _, err := field.Int64(); err == nil {
+			obj[name] = rand.Int64N(1 << 53) // stays exact as a JSON number
+		} else {
+			f, _ := field.Float64()
+			obj[name] = rand.Float64() * (2*math.Abs(f) + 1) // around the magnitude of the original
+		}
+	}
+}
+
+// randomSuffix returns 8 random hex characters.
+func randomSuffix() string {
+	return fmt.Sprintf("%08x", rand.Uint32())
+}
@@ -0,0 +1,135 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"spitha/datagen/datagen/config"
+	"strings"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// newTestMirror returns a mirror without a consumer, for amplify and copy.
+func newTestMirror(cm config.MirrorConfig) *mirrorSource {
+	return &mirrorSource{cfg: cm, fields: cm.Fields(), records: make(chan *kgo.Record, MIRROR_BUFFER_RECORDS)}
+}
+
+func TestMirrorAmplify(t *testing.T) {
+	s := newTestMirror(config.MirrorConfig{
+		Amplification:   3,
+		RandomizeKey:    true,
+		RandomizeFields: "id,customer.name,customer.missing,amount",
+	})
+	value := `{"id":42,"amount":12.5,"currency":"EUR","customer":{"name":"Ernie","country":"DE"}}`
+	rec := &kgo.Record{
+		Key:     []byte("order-1"),
+		Value:   []byte(value),
+		Headers: []kgo.RecordHeader{{Key: "source", Value: []byte("orders")}},
+	}
+	if !s.amplify(context.Background(), rec) {
+		t.Fatal("amplify: ctx done")
+	}
+	if len(s.records) != 3 {
+		t.Fatalf("%d records, want 3", len(s.records))
+	}
+
+	keys := make(map[string]bool)
+	values := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		out := <-s.records
+		if out == rec {
+			t.Fatal("the consumed record is produced")
+		}
+		if !strings.HasPrefix(string(out.Key), "order-1-") || len(out.Key) != len("order-1-")+8 {
+			t.Errorf("key %q, want order-1- and a random suffix", out.Key)
+		}
+		keys[string(out.Key)] = true
+		values[string(out.Value)] = true
+
+		var got struct {
+			Id       json.Number `json:"id"`
+			Amount   float64     `json:"amount"`
+			Currency string      `json:"currency"`
+			Customer map[string]string
+		}
+		dec := json.NewDecoder(bytes.NewReader(out.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("value %s: %v", out.Value, err)
+		}
+		if _, err := got.Id.Int64(); err != nil {
+			t.Errorf("id %s is not an integer", got.Id)
+		}
+		if got.Amount < 0 || got.Amount > 2*12.5+1 {
+			t.Errorf("amount %v, want around 12.5", got.Amount)
+		}
+		if !strings.HasPrefix(got.Customer["name"], "Ernie-") {
+			t.Errorf("customer.name %q, want Ernie- and a random suffix", got.Customer["name"])
+		}
+		if _, ok := got.Customer["missing"]; ok || got.Currency != "EUR" || got.Customer["country"] != "DE" {
+			t.Errorf("fields not in randomize-fields changed: %s", out.Value)
+		}
+
+		// the headers are copied, not shared with the consumed record
+		out.Headers[0].Value = []byte("changed")
+	}
+	if len(keys) != 3 || len(values) != 3 {
+		t.Errorf("%d keys and %d values for 3 copies, want every copy randomised", len(keys), len(values))
+	}
+	if string(rec.Key) != "order-1" || string(rec.Value) != value || string(rec.Headers[0].Value) != "orders" {
+		t.Errorf("consumed record changed: %+v", rec)
+	}
+
+	// a done ctx ends the amplification
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	full := newTestMirror(config.MirrorConfig{Amplification: 2})
+	full.records = make(chan *kgo.Record)
+	if full.amplify(ctx, rec) {
+		t.Error("amplify with a done ctx: true")
+	}
+}
+
+func TestMirrorCopyKeepsRecords(t *testing.T) {
+	// without randomisation the copies are the consumed record
+	s := newTestMirror(config.MirrorConfig{Amplification: 2})
+	rec := &kgo.Record{Key: []byte("k"), Value: []byte(`{"id":1}`)}
+	for i := 0; i < 2; i++ {
+		out := s.copy(rec)
+		if string(out.Key) != "k" || string(out.Value) != `{"id":1}` {
+			t.Errorf("copy %d: %+v", i, out)
+		}
+	}
+}
+
+func TestRandomizeKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		want func(out []byte) bool
+	}{
+		{name: "no key", key: nil, want: func(out []byte) bool { return out == nil }},
+		{name: "text", key: []byte("abc"), want: func(out []byte) bool { return strings.HasPrefix(string(out), "abc-") }},
+		{name: "json string", key: []byte(`"abc"`), want: func(out []byte) bool {
+			var s string
+			return json.Unmarshal(out, &s) == nil && strings.HasPrefix(s, "abc-")
+		}},
+		{name: "binary", key: []byte{0, 1}, want: func(out []byte) bool { return bytes.HasPrefix(out, []byte{0, 1, '-'}) }},
+	}
+	for _, tt := range tests {
+		if out := randomizeKey(tt.key); !tt.want(out) {
+			t.Errorf("%s: %q", tt.name, out)
+		}
+	}
+}
+
+func TestRandomizeFieldsLeavesOtherValues(t *testing.T) {
+	fields := [][]string{{"id"}, {"customer", "id"}}
+	for _, v := range []string{`not json`, `[1,2]`, `"id"`, `null`, `{"customer":"not an object"}`} {
+		if out := randomizeFields([]byte(v), fields); string(out) != v {
+			t.Errorf("%s: %s", v, out)
+		}
+	}
+}
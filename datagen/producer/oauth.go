@@ -0,0 +1,119 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"spitha/datagen/datagen/config"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/oauth"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+/**********************************************************************
+**                                                                   **
+**                         OAUTHBEARER support                       **
+**                                                                   **
+***********************************************************************/
+const OIDC_WELL_KNOWN_PATH = "/.well-known/openid-configuration"
+
+// oauthMechanism returns an OAUTHBEARER mechanism that asks the token source on
+// every (re)authentication.
+func oauthMechanism(cp config.ProducerConfig) (sasl.Mechanism, error) {
+	tokens, err := oauthTokenSource(context.Background(), config.OauthConfig{
+		ClientId:         cp.Sasl.ClientId,
+		ClientSecret:     cp.Sasl.ClientSecret,
+		TokenEndpoint:    cp.Sasl.TokenEndpoint,
+		OidcDiscoveryUrl: cp.Sasl.OidcDiscoveryUrl,
+		Scopes:           cp.Sasl.Scopes,
+		Audience:         cp.Sasl.Audience,
+		TokenParams:      cp.Sasl.TokenParams,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oauth.Oauth(func(ctx context.Context) (oauth.Auth, error) {
+		token, err := tokens.Token()
+		if err != nil {
+			return oauth.Auth{}, fmt.Errorf("failed to refresh token : %w", err)
+		}
+		return oauth.Auth{Token: token.AccessToken}, nil
+	}), nil
+}
+
+// oauthTokenSource returns a client credentials token source. It caches the
+// token and fetches a new one shortly before it expires.
+func oauthTokenSource(ctx context.Context, o config.OauthConfig) (oauth2.TokenSource, error) {
+	tokenURL := o.TokenEndpoint
+	if o.OidcDiscoveryUrl != "" {
+		var err error
+		if tokenURL, err = discoverTokenEndpoint(ctx, o.OidcDiscoveryUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	params := url.Values{}
+	for key, value := range o.TokenParams {
+		params.Set(key, value)
+	}
+	if o.Audience != "" {
+		params.Set("audience", o.Audience)
+	}
+
+	o2Config := &clientcredentials.Config{
+		ClientID:       o.ClientId,
+		ClientSecret:   o.ClientSecret,
+		TokenURL:       tokenURL,
+		Scopes:         o.Scopes,
+		EndpointParams: params,
+	}
+	tokens := o2Config.TokenSource(ctx)
+
+	// get the first AccessToken now so a bad config fails at startup
+	if _, err := tokens.Token(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// discoverTokenEndpoint reads the token endpoint from an OpenID provider
+// configuration. issuer is either the issuer url or the full .well-known url.
+func discoverTokenEndpoint(ctx context.Context, issuer string) (string, error) {
+	discoveryURL := issuer
+	if !strings.Contains(issuer, "/.well-known/") {
+		discoveryURL = strings.TrimSuffix(issuer, "/") + OIDC_WELL_KNOWN_PATH
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc discovery: %s returned %s", discoveryURL, resp.Status)
+	}
+
+	var provider struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return "", fmt.Errorf("oidc discovery: %w", err)
+	}
+	if provider.TokenEndpoint == "" {
+		return "", fmt.Errorf("oidc discovery: %s has no token_endpoint", discoveryURL)
+	}
+	return provider.TokenEndpoint, nil
+}
@@ -0,0 +1,447 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/value"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	PREFLIGHT_PASS    = "PASS"
+	PREFLIGHT_WARN    = "WARN"
+	PREFLIGHT_FAIL    = "FAIL"
+	PREFLIGHT_TIMEOUT = 30 * time.Second
+)
+
+/**********************************************************************
+**                                                                   **
+**                         Preflight report                          **
+**                                                                   **
+***********************************************************************/
+// PreflightCheck is a single row of the preflight report.
+type PreflightCheck struct {
+	Check  string
+	Status string // PASS, WARN or FAIL
+	Detail string
+}
+
+// PreflightReport lists every check in the order it ran.
+type PreflightReport []PreflightCheck
+
+func (r *PreflightReport) add(check string, status string, format string, args ...interface{}) {
+	*r = append(*r, PreflightCheck{Check: check, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// addErr adds a FAIL row for err, or a PASS row with detail when err is nil.
+func (r *PreflightReport) addErr(check string, err error, detail string) {
+	if err != nil {
+		r.add(check, PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	r.add(check, PREFLIGHT_PASS, "%s", detail)
+}
+
+// Failed reports whether any check failed.
+func (r PreflightReport) Failed() bool {
+	for _, c := range r {
+		if c.Status == PREFLIGHT_FAIL {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the report as a table.
+func (r PreflightReport) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, c := range r {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Check, c.Status, c.Detail)
+	}
+	tw.Flush()
+}
+
+/**********************************************************************
+**                                                                   **
+**                             Preflight                             **
+**                                                                   **
+***********************************************************************/
+// Preflight checks that every cluster and the schema registry of the config
+// can take the run, without producing: connectivity and auth per broker, API
+// versions, the topic and its configs, the ACLs of the configured principal and
+// the schema registry. With clusters, every check is named after its cluster.
+func Preflight(config *config.ConfigConfig) PreflightReport {
+	var report PreflightReport
+	ctx, cancel := context.WithTimeout(context.Background(), PREFLIGHT_TIMEOUT)
+	defer cancel()
+
+	for _, t := range config.Targets() {
+		var checks PreflightReport
+		preflightCluster(ctx, config, t, &checks)
+		for _, c := range checks {
+			if len(config.Clusters) > 0 {
+				c.Check = t.Name + " " + c.Check
+			}
+			report = append(report, c)
+		}
+	}
+
+	/*******************************
+	**   Schema Registry
+	********************************/
+	if urls := config.Producer.SchemaRegistry.Server.Urls; urls != "" {
+		srClient, err := newSchemaRegistryClient(config.Producer)
+		if err == nil {
+			var subjects []string
+			subjects, err = srClient.Subjects(ctx)
+			report.addErr("schema registry "+urls, err, fmt.Sprintf("reachable, %d subjects", len(subjects)))
+		} else {
+			report.add("schema registry "+urls, PREFLIGHT_FAIL, "%s", err)
+		}
+	}
+	return report
+}
+
+// preflightCluster checks a single cluster.
+func preflightCluster(ctx context.Context, config *config.ConfigConfig, target config.Target, report *PreflightReport) {
+	opts, err := clientOpts(config, target)
+	if err != nil {
+		report.add("client", PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		report.add("client", PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	defer client.Close()
+	adminClient := kadm.NewClient(client)
+
+	/*******************************
+	**   Brokers - Connect, Auth
+	********************************/
+	metadata, err := adminClient.BrokerMetadata(ctx)
+	report.addErr("bootstrap "+target.BootstrapServer, err, fmt.Sprintf("cluster %s, %d brokers", metadata.Cluster, len(metadata.Brokers)))
+	if err != nil {
+		return
+	}
+
+	/*******************************
+	**   Brokers - Api versions
+	********************************/
+	versions, err := adminClient.ApiVersions(ctx)
+	if err != nil {
+		report.add("api versions", PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	required := requiredApiKeys(config)
+	for _, b := range metadata.Brokers {
+		name := fmt.Sprintf("broker %d %s:%d", b.NodeID, b.Host, b.Port)
+		v := versions[b.NodeID]
+		if v.Err != nil {
+			report.add(name, PREFLIGHT_FAIL, "%s", v.Err)
+			continue
+		}
+		var missing []string
+		for _, key := range required {
+			if _, ok := v.KeyMaxVersion(key); !ok {
+				missing = append(missing, kmsg.NameForKey(key))
+			}
+		}
+		if len(missing) > 0 {
+			report.add(name, PREFLIGHT_FAIL, "missing api %s", strings.Join(missing, ", "))
+			continue
+		}
+		report.add(name, PREFLIGHT_PASS, "connected, %s", v.VersionGuess())
+	}
+
+	/*******************************
+	**   Topic, ACLs
+	********************************/
+	for _, ct := range config.Topics() {
+		preflightTopic(ctx, adminClient, ct, report)
+	}
+	preflightProducerId(ctx, client, config, target, report)
+}
+
+// requiredApiKeys lists the requests the run sends with config.
+func requiredApiKeys(config *config.ConfigConfig) []int16 {
+	ct := config.Topic
+	keys := []int16{
+		kmsg.Produce.Int16(),
+		kmsg.Metadata.Int16(),
+		kmsg.CreateTopics.Int16(),
+	}
+	switch ct.IfExists {
+	case value.TOPIC_IF_EXISTS_ALTER:
+		keys = append(keys, kmsg.IncrementalAlterConfigs.Int16())
+	case value.TOPIC_IF_EXISTS_FAIL_ON_DIFF:
+		keys = append(keys, kmsg.DescribeConfigs.Int16())
+	case value.TOPIC_IF_EXISTS_RECREATE:
+		keys = append(keys, kmsg.DeleteTopics.Int16())
+	}
+	if ct.TruncateBeforeRun {
+		keys = append(keys, kmsg.ListOffsets.Int16(), kmsg.DeleteRecords.Int16())
+	}
+	if ct.DeleteAfterRun {
+		keys = append(keys, kmsg.DeleteTopics.Int16())
+	}
+	if ct.Expand.Partitions > 0 {
+		keys = append(keys, kmsg.CreatePartitions.Int16())
+	}
+	if config.Producer.TransactionalID != "" {
+		keys = append(keys,
+			kmsg.InitProducerID.Int16(),
+			kmsg.FindCoordinator.Int16(),
+			kmsg.AddPartitionsToTxn.Int16(),
+			kmsg.EndTxn.Int16(),
+		)
+		if config.Datagen.Transaction.Verify {
+			keys = append(keys, kmsg.Fetch.Int16())
+		}
+	} else if idempotent(config.Producer) {
+		keys = append(keys, kmsg.InitProducerID.Int16())
+	}
+	return keys
+}
+
+// preflightTopic checks the topic, its configs and the write and describe ACLs
+// on it. A missing topic is checked with a create that only validates.
+func preflightTopic(ctx context.Context, adminClient *kadm.Client, ct config.TopicConfig, report *PreflightReport) {
+	name := "topic " + ct.Name
+	topics, err := adminClient.ListTopics(ctx, ct.Name)
+	if err != nil {
+		report.add(name, PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	if !topics.Has(ct.Name) {
+		responses, err := adminClient.ValidateCreateTopics(ctx, int32(ct.Partition), int16(ct.Replicafactor), topicConfigs(ct), ct.Name)
+		if err == nil {
+			var r kadm.CreateTopicResponse
+			if r, err = responses.On(ct.Name, nil); err == nil && r.Err != nil {
+				err = fmt.Errorf("%w %s", r.Err, r.ErrMessage)
+			}
+		}
+		if err != nil {
+			report.add(name, PREFLIGHT_FAIL, "missing and cannot be created: %s", err)
+			return
+		}
+		report.add(name, PREFLIGHT_PASS, "missing, can be created with %d partitions", ct.Partition)
+		return
+	}
+
+	detail, err := describeTopic(ctx, adminClient, ct.Name)
+	if err != nil {
+		report.add(name, PREFLIGHT_FAIL, "%s", err)
+		return
+	}
+	if problems := topicProblems(detail, ct.Partition); len(problems) > 0 {
+		report.add(name, PREFLIGHT_WARN, "exists, %s", strings.Join(problems, ", "))
+	} else {
+		report.add(name, PREFLIGHT_PASS, "exists, %d partitions, leaders and replicas in sync", len(detail.Partitions))
+	}
+
+	// configs
+	if len(ct.Configs) > 0 {
+		err := diffTopicConfigs(ctx, adminClient, ct)
+		switch {
+		case err == nil:
+			report.add(name+" configs", PREFLIGHT_PASS, "match topic.configs")
+		case ct.IfExists == value.TOPIC_IF_EXISTS_FAIL_ON_DIFF:
+			report.add(name+" configs", PREFLIGHT_FAIL, "%s", err)
+		case ct.IfExists == value.TOPIC_IF_EXISTS_KEEP:
+			report.add(name+" configs", PREFLIGHT_WARN, "%s, kept with if-exists keep", err)
+		default:
+			report.add(name+" configs", PREFLIGHT_PASS, "%s, applied with if-exists %s", err, ct.IfExists)
+		}
+	}
+
+	// acls, as reported by the broker for the connected principal
+	if len(detail.AuthorizedOperations) == 0 {
+		report.add(name+" acls", PREFLIGHT_WARN, "the broker does not report authorized operations")
+		return
+	}
+	var missing []string
+	for _, op := range []kmsg.ACLOperation{kmsg.ACLOperationWrite, kmsg.ACLOperationDescribe} {
+		if !hasOperation(detail.AuthorizedOperations, op) {
+			missing = append(missing, op.String())
+		}
+	}
+	if len(missing) > 0 {
+		report.add(name+" acls", PREFLIGHT_FAIL, "missing %s", strings.Join(missing, ", "))
+		return
+	}
+	report.add(name+" acls", PREFLIGHT_PASS, "WRITE, DESCRIBE allowed")
+}
+
+// preflightProducerId checks the idempotent write by asking for a producer id,
+// as the producer does before its first write. The transactional ids of the
+// workers are not asked for a producer id, which would fence a running datagen
+// with the same ids: DESCRIBE is checked by finding their coordinator and WRITE
+// by the ACLs on them.
+func preflightProducerId(ctx context.Context, client *kgo.Client, config *config.ConfigConfig, target config.Target, report *PreflightReport) {
+	if !idempotent(target.Producer) {
+		return
+	}
+	req := kmsg.NewPtrInitProducerIDRequest()
+	req.TransactionTimeoutMillis = -1
+	if _, err := initProducerId(ctx, client, req); err != nil {
+		report.add("idempotent write", PREFLIGHT_FAIL, "%s", err)
+	} else {
+		report.add("idempotent write", PREFLIGHT_PASS, "allowed")
+	}
+
+	if target.Producer.TransactionalID == "" {
+		return
+	}
+	var transactionalIds []string
+	for i := 1; i <= config.Datagen.GoRoutine; i++ {
+		transactionalIds = append(transactionalIds, workerTransactionalId(target.Producer.TransactionalID, i))
+	}
+	name := "transactional id " + transactionalIds[0]
+	if len(transactionalIds) > 1 {
+		name += ".." + transactionalIds[len(transactionalIds)-1]
+	}
+
+	// describe
+	coordinator := kmsg.NewPtrFindCoordinatorRequest()
+	coordinator.CoordinatorType = 1 // transaction
+	coordinator.CoordinatorKeys = transactionalIds
+	coordinator.CoordinatorKey = transactionalIds[0]
+	resp, err := coordinator.RequestWith(ctx, client)
+	if err == nil {
+		err = kerr.ErrorForCode(resp.ErrorCode)
+		for _, c := range resp.Coordinators {
+			if err == nil {
+				if err = kerr.ErrorForCode(c.ErrorCode); err != nil {
+					err = fmt.Errorf("%s: %w", c.Key, err)
+				}
+			}
+		}
+	}
+	if err != nil {
+		report.add(name, PREFLIGHT_FAIL, "describe: %s", err)
+		return
+	}
+
+	// write
+	status, detail := transactionalWrite(ctx, kadm.NewClient(client), target.Producer, transactionalIds)
+	report.add(name, status, "DESCRIBE allowed, %s", detail)
+}
+
+// transactionalWrite checks WRITE on every transactional id with the ACLs that
+// match it, literal, prefixed or wildcard. The connected principal is only known
+// with SASL PLAIN and SCRAM; for the others the ACLs of any principal count.
+// Super users are not listed in ACLs, so a missing ACL is only a warning.
+func transactionalWrite(ctx context.Context, adminClient *kadm.Client, cp config.ProducerConfig, transactionalIds []string) (string, string) {
+	b := kadm.NewACLs().
+		TransactionalIDs(transactionalIds...).
+		ResourcePatternType(kadm.ACLPatternMatch).
+		Operations(kadm.OpAny).
+		Allow().AllowHosts().
+		Deny().DenyHosts()
+	results, err := adminClient.DescribeACLs(ctx, b)
+	if err == nil {
+		for _, r := range results {
+			if r.Err != nil {
+				err = r.Err
+				break
+			}
+		}
+	}
+	if errors.Is(err, kerr.SecurityDisabled) {
+		return PREFLIGHT_PASS, "WRITE allowed, no authorizer"
+	}
+	if err != nil {
+		return PREFLIGHT_WARN, fmt.Sprintf("WRITE not checked, describe acls: %s", err)
+	}
+
+	principal := ""
+	switch cp.Sasl.Mechanism {
+	case value.SASL_PLAIN, value.SASL_SCRAM_SHA_256, value.SASL_SCRAM_SHA_512:
+		principal = "User:" + cp.Sasl.Username
+	}
+	// every result is the filter of one id, matching literal, prefixed and wildcard ACLs
+	allowed := make(map[string]bool)
+	var denied, missing, principals []string
+	for _, r := range results {
+		id := *r.Name
+		for _, acl := range r.Described {
+			if acl.Operation != kadm.OpWrite && acl.Operation != kadm.OpAll {
+				continue
+			}
+			if principal != "" && acl.Principal != principal && acl.Principal != "User:*" {
+				continue
+			}
+			if acl.Permission == kmsg.ACLPermissionTypeDeny {
+				if !slices.Contains(denied, id) {
+					denied = append(denied, id)
+				}
+				continue
+			}
+			allowed[id] = true
+			if !slices.Contains(principals, acl.Principal) {
+				principals = append(principals, acl.Principal)
+			}
+		}
+	}
+	for _, id := range transactionalIds {
+		if !allowed[id] {
+			missing = append(missing, id)
+		}
+	}
+	switch {
+	case principal != "" && len(denied) > 0:
+		return PREFLIGHT_FAIL, fmt.Sprintf("WRITE denied to %s on %s", principal, strings.Join(denied, ", "))
+	case len(missing) > 0:
+		return PREFLIGHT_WARN, fmt.Sprintf("no ACL allows WRITE on %s, unless the principal is a super user", strings.Join(missing, ", "))
+	case principal == "" && len(denied) > 0:
+		return PREFLIGHT_WARN, fmt.Sprintf("WRITE allowed to %s and denied on %s, the connected principal is not known", strings.Join(principals, ", "), strings.Join(denied, ", "))
+	case principal == "":
+		return PREFLIGHT_WARN, fmt.Sprintf("WRITE allowed to %s, the connected principal is not known", strings.Join(principals, ", "))
+	}
+	return PREFLIGHT_PASS, fmt.Sprintf("WRITE allowed to %s", principal)
+}
+
+func initProducerId(ctx context.Context, client *kgo.Client, req *kmsg.InitProducerIDRequest) (*kmsg.InitProducerIDResponse, error) {
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// idempotent reports whether the producer gets a producer id, following delivery.
+func idempotent(cp config.ProducerConfig) bool {
+	if cp.TransactionalID != "" {
+		return true
+	}
+	if cp.EnableIdempotence != nil {
+		return *cp.EnableIdempotence
+	}
+	return cp.Acks == "all" || cp.Acks == "-1"
+}
+
+func hasOperation(ops []kadm.ACLOperation, op kmsg.ACLOperation) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
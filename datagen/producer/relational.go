@@ -0,0 +1,248 @@
+package producer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/message/relational"
+	"spitha/datagen/datagen/value"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                         Relational source                         **
+**                                                                   **
+***********************************************************************/
+// relationalSource makes the records of customers, products, orders, their
+// items and payments, each entity to its own topic. Child records only
+// reference parents that were handed out before them: a child without a
+// parent to reference gets a new parent first. Payments pay the items of
+// their order, after which the order gets no more items.
+type relationalSource struct {
+	topic   string // topic.name, the entity topics are <topic.name>.<entity>
+	weights []int  // ratio of each of value.RELATIONAL_ENTITIES
+	total   int
+	keyPool int
+
+	mu        sync.Mutex // guards the fields below
+	customers recentPool[string]
+	products  recentPool[relational.Product]
+	unpaid    []*openOrder  // orders without a payment, oldest first
+	pending   []*kgo.Record // parents made for a child, handed out before it
+}
+
+// openOrder is an order that has not been paid yet.
+type openOrder struct {
+	id    string
+	total float64 // of its items
+}
+
+func newRelationalSource(cfg *config.ConfigConfig) *relationalSource {
+	cr := cfg.Datagen.Message.Relational
+	s := &relationalSource{
+		topic:     cfg.Topic.Name,
+		keyPool:   cr.KeyPool,
+		customers: recentPool[string]{size: cr.KeyPool},
+		products:  recentPool[relational.Product]{size: cr.KeyPool},
+	}
+	for _, entity := range value.RELATIONAL_ENTITIES {
+		s.weights = append(s.weights, cr.Ratio(entity))
+		s.total += cr.Ratio(entity)
+	}
+	logger.Log.Info(fmt.Sprintf("relational : customers %d, products %d, orders %d, order items %d, payments %d to %s.<entity>",
+		cr.Ratios.Customers, cr.Ratios.Products, cr.Ratios.Orders, cr.Ratios.OrderItems, cr.Ratios.Payments, s.topic))
+	return s
+}
+
+// next returns the next record. It never waits, and returns nil when the
+// record could not be made.
+func (s *relationalSource) next(<-chan struct{}) *kgo.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		// the parents queued before a failure are still handed out
+		if err := s.make(s.pick()); err != nil {
+			logger.Log.Error(fmt.Sprintln("relational : ", err))
+		}
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+	rec := s.pending[0]
+	s.pending = s.pending[1:]
+	return rec
+}
+
+func (s *relationalSource) Close() {}
+
+// pick returns an entity by the ratios.
+func (s *relationalSource) pick() string {
+	n := rand.IntN(s.total)
+	for i, weight := range s.weights {
+		if n < weight {
+			return value.RELATIONAL_ENTITIES[i]
+		}
+		n -= weight
+	}
+	return value.RELATIONAL_ENTITIES[len(value.RELATIONAL_ENTITIES)-1]
+}
+
+func (s *relationalSource) make(entity string) error {
+	var err error
+	switch entity {
+	case value.ENTITY_CUSTOMERS:
+		_, err = s.customer()
+	case value.ENTITY_PRODUCTS:
+		_, err = s.product()
+	case value.ENTITY_ORDERS:
+		_, err = s.order()
+	case value.ENTITY_ORDER_ITEMS:
+		err = s.orderItem(nil)
+	case value.ENTITY_PAYMENTS:
+		err = s.payment()
+	}
+	return err
+}
+
+/*******************************
+**   Entities
+********************************/
+// An entity is only referenced once its record is queued, so a record that
+// could not be made is never a parent.
+
+func (s *relationalSource) customer() (string, error) {
+	c := relational.MakeRandomCustomer()
+	if err := s.emit(value.ENTITY_CUSTOMERS, c.CustomerID, c); err != nil {
+		return "", err
+	}
+	s.customers.add(c.CustomerID)
+	return c.CustomerID, nil
+}
+
+func (s *relationalSource) product() (relational.Product, error) {
+	p := relational.MakeRandomProduct()
+	if err := s.emit(value.ENTITY_PRODUCTS, p.ProductID, p); err != nil {
+		return relational.Product{}, err
+	}
+	s.products.add(p)
+	return p, nil
+}
+
+// order makes an order of a recent customer. Once more than key-pool orders
+// are unpaid the oldest is dropped and never paid.
+func (s *relationalSource) order() (*openOrder, error) {
+	customerID, ok := s.customers.pick()
+	if !ok {
+		var err error
+		if customerID, err = s.customer(); err != nil {
+			return nil, err
+		}
+	}
+	o := relational.MakeRandomOrder(customerID)
+	if err := s.emit(value.ENTITY_ORDERS, o.OrderID, o); err != nil {
+		return nil, err
+	}
+	open := &openOrder{id: o.OrderID}
+	s.unpaid = append(s.unpaid, open)
+	if len(s.unpaid) > s.keyPool {
+		s.unpaid = s.unpaid[1:]
+	}
+	return open, nil
+}
+
+// orderItem adds a recent product to order, or to a recent unpaid order when nil.
+func (s *relationalSource) orderItem(order *openOrder) error {
+	var err error
+	if order == nil {
+		if len(s.unpaid) == 0 {
+			if order, err = s.order(); err != nil {
+				return err
+			}
+		} else {
+			order = s.unpaid[rand.IntN(len(s.unpaid))]
+		}
+	}
+	product, ok := s.products.pick()
+	if !ok {
+		if product, err = s.product(); err != nil {
+			return err
+		}
+	}
+	item := relational.MakeRandomOrderItem(order.id, product)
+	if err := s.emit(value.ENTITY_ORDER_ITEMS, item.OrderItemID, item); err != nil {
+		return err
+	}
+	order.total += item.Total()
+	return nil
+}
+
+// payment pays a recent unpaid order, which gets an item first if it has none.
+func (s *relationalSource) payment() error {
+	if len(s.unpaid) == 0 {
+		if _, err := s.order(); err != nil {
+			return err
+		}
+	}
+	i := rand.IntN(len(s.unpaid))
+	order := s.unpaid[i]
+	if order.total == 0 {
+		if err := s.orderItem(order); err != nil {
+			return err
+		}
+	}
+	p := relational.MakeRandomPayment(order.id, order.total)
+	if err := s.emit(value.ENTITY_PAYMENTS, p.PaymentID, p); err != nil {
+		return err
+	}
+	s.unpaid = append(s.unpaid[:i], s.unpaid[i+1:]...)
+	return nil
+}
+
+// emit queues the record of an entity, keyed by its id.
+func (s *relationalSource) emit(entity string, id string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", entity, id, err)
+	}
+	s.pending = append(s.pending, &kgo.Record{
+		Topic:     s.topic + "." + entity,
+		Key:       []byte(id),
+		Value:     b,
+		Timestamp: time.Now(),
+	})
+	return nil
+}
+
+/*******************************
+**   Recent keys
+********************************/
+// recentPool keeps the last size items added.
+type recentPool[T any] struct {
+	items []T
+	next  int // index replaced by the next add once full
+	size  int
+}
+
+func (p *recentPool[T]) add(item T) {
+	if len(p.items) < p.size {
+		p.items = append(p.items, item)
+		return
+	}
+	p.items[p.next] = item
+	p.next = (p.next + 1) % p.size
+}
+
+// pick returns a random item, false when the pool is empty.
+func (p *recentPool[T]) pick() (T, bool) {
+	if len(p.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return p.items[rand.IntN(len(p.items))], true
+}
@@ -0,0 +1,137 @@
+package producer
+
+import (
+	"encoding/json"
+	"math"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/message/relational"
+	"spitha/datagen/datagen/value"
+	"strings"
+	"testing"
+)
+
+func newTestRelationalSource(customers, products, orders, orderItems, payments, keyPool int) *relationalSource {
+	cfg := &config.ConfigConfig{}
+	cfg.Topic.Name = "shop"
+	cr := &cfg.Datagen.Message.Relational
+	cr.Ratios.Customers, cr.Ratios.Products, cr.Ratios.Orders = customers, products, orders
+	cr.Ratios.OrderItems, cr.Ratios.Payments = orderItems, payments
+	cr.KeyPool = keyPool
+	return newRelationalSource(cfg)
+}
+
+// TestRelationalIntegrity checks that every child record references a parent
+// produced before it, and that payments pay the items of their order.
+func TestRelationalIntegrity(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *relationalSource
+	}{
+		{name: "default ratios", s: newTestRelationalSource(1, 1, 2, 4, 2, 100)},
+		{name: "children only", s: newTestRelationalSource(0, 0, 0, 1, 1, 10)},
+		{name: "payments only", s: newTestRelationalSource(0, 0, 0, 0, 1, 1)},
+		{name: "small key pool", s: newTestRelationalSource(1, 1, 5, 5, 5, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customers := make(map[string]bool)
+			products := make(map[string]float64)
+			orders := make(map[string]float64) // total of the items
+			paid := make(map[string]bool)
+			counts := make(map[string]int)
+
+			for i := 0; i < 5000; i++ {
+				rec := tt.s.next(nil)
+				if rec == nil {
+					t.Fatalf("record %d: nil", i)
+				}
+				entity, ok := strings.CutPrefix(rec.Topic, "shop.")
+				if !ok {
+					t.Fatalf("record %d: topic %s", i, rec.Topic)
+				}
+				counts[entity]++
+				decode := func(v interface{}) {
+					t.Helper()
+					if err := json.Unmarshal(rec.Value, v); err != nil {
+						t.Fatalf("%s %s: %v", entity, rec.Value, err)
+					}
+				}
+
+				var id string
+				switch entity {
+				case value.ENTITY_CUSTOMERS:
+					var c relational.Customer
+					decode(&c)
+					id = c.CustomerID
+					customers[id] = true
+				case value.ENTITY_PRODUCTS:
+					var p relational.Product
+					decode(&p)
+					id = p.ProductID
+					products[id] = p.Price
+				case value.ENTITY_ORDERS:
+					var o relational.Order
+					decode(&o)
+					id = o.OrderID
+					if !customers[o.CustomerID] {
+						t.Fatalf("order %s: customer %s not produced before", id, o.CustomerID)
+					}
+					orders[id] = 0
+				case value.ENTITY_ORDER_ITEMS:
+					var item relational.OrderItem
+					decode(&item)
+					id = item.OrderItemID
+					total, ok := orders[item.OrderID]
+					switch {
+					case !ok:
+						t.Fatalf("order item %s: order %s not produced before", id, item.OrderID)
+					case paid[item.OrderID]:
+						t.Fatalf("order item %s: order %s already paid", id, item.OrderID)
+					}
+					price, ok := products[item.ProductID]
+					if !ok {
+						t.Fatalf("order item %s: product %s not produced before", id, item.ProductID)
+					}
+					if item.UnitPrice != price {
+						t.Errorf("order item %s: unit price %v, product price %v", id, item.UnitPrice, price)
+					}
+					orders[item.OrderID] = total + item.Total()
+				case value.ENTITY_PAYMENTS:
+					var p relational.Payment
+					decode(&p)
+					id = p.PaymentID
+					total, ok := orders[p.OrderID]
+					switch {
+					case !ok:
+						t.Fatalf("payment %s: order %s not produced before", id, p.OrderID)
+					case paid[p.OrderID]:
+						t.Fatalf("payment %s: order %s paid twice", id, p.OrderID)
+					case total == 0:
+						t.Fatalf("payment %s: order %s has no items", id, p.OrderID)
+					case math.Abs(p.Amount-math.Round(total*100)/100) > 1e-9:
+						t.Errorf("payment %s: amount %v, items total %v", id, p.Amount, total)
+					}
+					paid[p.OrderID] = true
+				default:
+					t.Fatalf("record %d: unknown entity %s", i, entity)
+				}
+				if string(rec.Key) != id {
+					t.Errorf("%s: key %s, id %s", entity, rec.Key, id)
+				}
+			}
+			if counts[value.ENTITY_PAYMENTS] == 0 {
+				t.Errorf("no payments in %v", counts)
+			}
+		})
+	}
+}
+
+func TestRelationalRatios(t *testing.T) {
+	// parents made for children come on top of their own ratio
+	s := newTestRelationalSource(1, 0, 0, 0, 0, 10)
+	for i := 0; i < 100; i++ {
+		if rec := s.next(nil); rec.Topic != "shop."+value.ENTITY_CUSTOMERS {
+			t.Fatalf("topic %s, want only customers", rec.Topic)
+		}
+	}
+}
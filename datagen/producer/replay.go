@@ -0,0 +1,517 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                           Replay source                           **
+**                                                                   **
+***********************************************************************/
+// replaySource hands the records of a file to the workers in file order. With
+// preserve-timing a record is due as long after the first record of the pass
+// as its timestamp is, divided by speed.
+type replaySource struct {
+	cfg   config.ReplayConfig
+	onEnd func() // called once the file ended with at-eof stop
+
+	mu      sync.Mutex // guards the fields below
+	file    *os.File
+	reader  replayReader
+	records int64     // records read in the current pass
+	total   int64     // records read in every pass
+	base    time.Time // when the first record of the pass was read
+	first   time.Time // timestamp of the first record of the pass
+	ended   bool      // the file ended with at-eof stop, or the source was closed
+}
+
+// replayReader reads the records of a file. It returns io.EOF at the end.
+type replayReader interface {
+	next() (*kgo.Record, error)
+}
+
+// openReplay opens the file of cfg. onEnd is called once the file ended with at-eof stop.
+func openReplay(cfg config.ReplayConfig, onEnd func()) (*replaySource, error) {
+	s := &replaySource{cfg: cfg, onEnd: onEnd}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	logger.Log.Info(fmt.Sprintln("replay file : ", cfg.Path))
+	return s, nil
+}
+
+// open starts a pass over the file. s.mu must be held once the source is shared.
+func (s *replaySource) open() error {
+	f, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	r, err := newReplayReader(s.cfg.Format, f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("replay %s: %w", s.cfg.Path, err)
+	}
+	s.file, s.reader, s.records = f, r, 0
+	return nil
+}
+
+// next returns the next record once it is due. At the end of the file and when
+// the source is closed it waits for stop and returns nil.
+func (s *replaySource) next(stop <-chan struct{}) *kgo.Record {
+	rec, due, ok := s.read()
+	if !ok {
+		<-stop
+		return nil
+	}
+	if wait := time.Until(due); wait > 0 {
+		sleepUnlessStopped(wait, stop)
+		if stopped(stop) {
+			return nil
+		}
+	}
+	return rec
+}
+
+// read reads the next record and when it is due.
+func (s *replaySource) read() (*kgo.Record, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.ended {
+		rec, err := s.reader.next()
+		switch {
+		case err == nil:
+			return rec, s.due(rec), true
+		case errors.Is(err, io.EOF):
+			s.endOfFile()
+		default:
+			// a bad record is skipped, a broken file ends the pass
+			logger.Log.Error(fmt.Sprintln("replay : ", err))
+			var re *replayRecordError
+			if !errors.As(err, &re) {
+				s.endOfFile()
+			}
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// due returns when rec is due and, unless keep-timestamps is set, resets the
+// record timestamp to the produce time.
+func (s *replaySource) due(rec *kgo.Record) time.Time {
+	now := time.Now()
+	s.records++
+	s.total++
+	ts := rec.Timestamp
+	if !s.cfg.KeepTimestamps {
+		rec.Timestamp = time.Time{}
+	}
+	if !s.cfg.PreserveTiming || ts.IsZero() {
+		return now
+	}
+	if s.first.IsZero() {
+		s.base, s.first = now, ts
+	}
+	return s.base.Add(time.Duration(float64(ts.Sub(s.first)) / s.cfg.Speed))
+}
+
+// endOfFile starts the next pass with at-eof loop, or ends the replay.
+func (s *replaySource) endOfFile() {
+	s.file.Close()
+	s.first = time.Time{}
+	if s.cfg.AtEOF == value.REPLAY_AT_EOF_LOOP && s.records > 0 {
+		err := s.open()
+		if err == nil {
+			return
+		}
+		logger.Log.Error(fmt.Sprintln(err))
+	}
+	s.ended = true
+	logger.Log.Info(fmt.Sprintf("replay of %s ended after %d records", s.cfg.Path, s.total))
+	if s.onEnd != nil {
+		go s.onEnd()
+	}
+}
+
+// Close ends the replay without calling onEnd, e.g. when a reload replaced it.
+func (s *replaySource) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.ended = true
+		s.file.Close()
+	}
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Replay formats                           **
+**                                                                   **
+***********************************************************************/
+// replayRecordError is a single record that could not be read.
+type replayRecordError struct {
+	line int64
+	err  error
+}
+
+func (e *replayRecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.line, e.err)
+}
+
+func newReplayReader(format string, r io.Reader) (replayReader, error) {
+	switch format {
+	case value.REPLAY_FORMAT_JSONL:
+		return &jsonlReader{r: bufio.NewReader(r), parse: parseJsonlRecord}, nil
+	case value.REPLAY_FORMAT_KCAT:
+		return &jsonlReader{r: bufio.NewReader(r), parse: parseKcatRecord}, nil
+	case value.REPLAY_FORMAT_CSV:
+		return newCsvReader(r)
+	case value.REPLAY_FORMAT_AVRO:
+		return newAvroReader(r)
+	}
+	return nil, fmt.Errorf("unknown replay format %q", format)
+}
+
+/*******************************
+**   JSON lines and kcat -J
+********************************/
+type jsonlReader struct {
+	r     *bufio.Reader
+	line  int64
+	parse func(line []byte) (*kgo.Record, error)
+}
+
+func (jr *jsonlReader) next() (*kgo.Record, error) {
+	for {
+		line, err := jr.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue // blank line
+		}
+		jr.line++
+		rec, perr := jr.parse(line)
+		if perr != nil {
+			return nil, &replayRecordError{line: jr.line, err: perr}
+		}
+		return rec, nil
+	}
+}
+
+// parseJsonlRecord reads a record as written by the jsonl sink. A line without
+// a value field is the value itself, e.g. a line of an export.
+func parseJsonlRecord(line []byte) (*kgo.Record, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["value"]; !ok {
+		return &kgo.Record{Value: compactJSON(line)}, nil
+	}
+	var sr struct {
+		Timestamp     time.Time       `json:"timestamp"`
+		Key           json.RawMessage `json:"key"`
+		KeyEncoding   string          `json:"key_encoding"`
+		Headers       []sinkHeader    `json:"headers"`
+		Value         json.RawMessage `json:"value"`
+		ValueEncoding string          `json:"value_encoding"`
+	}
+	if err := json.Unmarshal(line, &sr); err != nil {
+		return nil, err
+	}
+	rec := &kgo.Record{Timestamp: sr.Timestamp}
+	var err error
+	if rec.Key, err = decodeBytes(sr.Key, sr.KeyEncoding); err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	if rec.Value, err = decodeBytes(sr.Value, sr.ValueEncoding); err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	for _, h := range sr.Headers {
+		header := kgo.RecordHeader{Key: h.Key, Value: []byte(h.Value)}
+		if h.ValueEncoding == ENCODING_BASE64 {
+			if header.Value, err = base64.StdEncoding.DecodeString(h.Value); err != nil {
+				return nil, fmt.Errorf("header %s: %w", h.Key, err)
+			}
+		}
+		rec.Headers = append(rec.Headers, header)
+	}
+	return rec, nil
+}
+
+// parseKcatRecord reads a record of kcat -C -J.
+func parseKcatRecord(line []byte) (*kgo.Record, error) {
+	var kr struct {
+		Ts      int64    `json:"ts"`
+		Headers []string `json:"headers"` // name, value, name, value...
+		Key     *string  `json:"key"`
+		Payload *string  `json:"payload"`
+	}
+	if err := json.Unmarshal(line, &kr); err != nil {
+		return nil, err
+	}
+	rec := &kgo.Record{}
+	if kr.Ts > 0 {
+		rec.Timestamp = time.UnixMilli(kr.Ts)
+	}
+	if kr.Key != nil {
+		rec.Key = []byte(*kr.Key)
+	}
+	if kr.Payload != nil {
+		rec.Value = []byte(*kr.Payload)
+	}
+	for i := 0; i+1 < len(kr.Headers); i += 2 {
+		rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: kr.Headers[i], Value: []byte(kr.Headers[i+1])})
+	}
+	return rec, nil
+}
+
+// decodeBytes is the reverse of encodeBytes. null is nil. Without an encoding,
+// e.g. a line written by hand, a JSON string is its text and any other JSON is
+// kept as is.
+func decodeBytes(raw json.RawMessage, encoding string) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	switch encoding {
+	case ENCODING_JSON:
+		return compactJSON(raw), nil
+	case ENCODING_TEXT, ENCODING_BASE64:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%s encoding: %w", encoding, err)
+		}
+		if encoding == ENCODING_BASE64 {
+			return base64.StdEncoding.DecodeString(s)
+		}
+		return []byte(s), nil
+	case "":
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return compactJSON(raw), nil
+		}
+		return []byte(s), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+func compactJSON(raw []byte) []byte {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return bytes.TrimSpace(raw)
+	}
+	return b.Bytes()
+}
+
+/*******************************
+**   CSV
+********************************/
+// csvReader reads a CSV file with a header row. The key, value and timestamp
+// columns and header.<name> columns make the record. Without a value column
+// the other columns are the value as a JSON object, with dotted columns such
+// as address.city nested, e.g. a file of an export.
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+	line    int64
+}
+
+func newCsvReader(r io.Reader) (*csvReader, error) {
+	cr := &csvReader{r: csv.NewReader(r)}
+	cr.r.FieldsPerRecord = -1
+	columns, err := cr.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	cr.columns = columns
+	return cr, nil
+}
+
+func (cr *csvReader) next() (*kgo.Record, error) {
+	row, err := cr.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			cr.line++
+			return nil, &replayRecordError{line: cr.line, err: err}
+		}
+		return nil, err
+	}
+	cr.line++
+	rec := &kgo.Record{}
+	object := map[string]interface{}{}
+	hasValue := false
+	for i, column := range cr.columns {
+		if i >= len(row) {
+			break
+		}
+		cell := row[i]
+		switch {
+		case column == "key":
+			if cell != "" {
+				rec.Key = []byte(cell)
+			}
+		case column == "value":
+			rec.Value, hasValue = []byte(cell), true
+		case column == "timestamp":
+			if rec.Timestamp, err = parseTimestamp(cell); err != nil {
+				return nil, &replayRecordError{line: cr.line, err: err}
+			}
+		case strings.HasPrefix(column, "header."):
+			rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: strings.TrimPrefix(column, "header."), Value: []byte(cell)})
+		default:
+			setNested(object, strings.Split(column, "."), cell)
+		}
+	}
+	if !hasValue {
+		if rec.Value, err = json.Marshal(object); err != nil {
+			return nil, &replayRecordError{line: cr.line, err: err}
+		}
+	}
+	return rec, nil
+}
+
+// parseTimestamp reads RFC 3339 or epoch milliseconds.
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func setNested(object map[string]interface{}, path []string, cell string) {
+	for _, name := range path[:len(path)-1] {
+		child, ok := object[name].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			object[name] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = cell
+}
+
+/*******************************
+**   Avro object container
+********************************/
+// avroReader reads the records of an Avro object container file as JSON values.
+type avroReader struct {
+	dec    *ocf.Decoder
+	schema avro.Schema
+	line   int64
+}
+
+func newAvroReader(r io.Reader) (*avroReader, error) {
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := avro.Parse(string(dec.Metadata()["avro.schema"]))
+	if err != nil {
+		return nil, err
+	}
+	return &avroReader{dec: dec, schema: schema}, nil
+}
+
+func (ar *avroReader) next() (*kgo.Record, error) {
+	if !ar.dec.HasNext() {
+		if err := ar.dec.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	ar.line++
+	var v interface{}
+	if err := ar.dec.Decode(&v); err != nil {
+		return nil, &replayRecordError{line: ar.line, err: err}
+	}
+	b, err := json.Marshal(plainAvro(ar.schema, v))
+	if err != nil {
+		return nil, &replayRecordError{line: ar.line, err: err}
+	}
+	return &kgo.Record{Value: b}, nil
+}
+
+// plainAvro drops the type names the decoder wraps union values in, e.g.
+// {"AddressInfo": {...}} becomes {...}.
+func plainAvro(schema avro.Schema, v interface{}) interface{} {
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for _, f := range s.Fields() {
+			if fv, ok := m[f.Name()]; ok {
+				m[f.Name()] = plainAvro(f.Type(), fv)
+			}
+		}
+		return m
+	case *avro.UnionSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			return v
+		}
+		for name, inner := range m {
+			for _, t := range s.Types() {
+				if unionTypeName(t) == name {
+					return plainAvro(t, inner)
+				}
+			}
+		}
+		return v
+	case *avro.ArraySchema:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		for i := range items {
+			items[i] = plainAvro(s.Items(), items[i])
+		}
+		return items
+	case *avro.MapSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for k := range m {
+			m[k] = plainAvro(s.Values(), m[k])
+		}
+		return m
+	}
+	return v
+}
+
+func unionTypeName(t avro.Schema) string {
+	if named, ok := t.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	return string(t.Type())
+}
@@ -0,0 +1,298 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"spitha/datagen/datagen/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestParseJsonlRecord(t *testing.T) {
+	ts := time.Date(2026, 10, 19, 10, 10, 9, 349207201, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		want    *kgo.Record
+		wantErr bool
+	}{
+		{
+			name: "sink line",
+			line: `{"topic":"datagen","partition":5,"timestamp":"2026-10-19T10:10:09.349207201Z","key":"Ernie","headers":[{"key":"datagen-seq","value":"1-1"}],"value":{"first_name":"Ernie", "last_name":"Cassin"}}`,
+			want: &kgo.Record{
+				Timestamp: ts,
+				Key:       []byte("Ernie"),
+				Headers:   []kgo.RecordHeader{{Key: "datagen-seq", Value: []byte("1-1")}},
+				Value:     []byte(`{"first_name":"Ernie","last_name":"Cassin"}`),
+			},
+		},
+		{
+			name: "base64",
+			line: `{"key":"AAE=","key_encoding":"base64","value":"aGk=","value_encoding":"base64"}`,
+			want: &kgo.Record{Key: []byte{0, 1}, Value: []byte("hi")},
+		},
+		{
+			name: "null key and text value",
+			line: `{"key":null,"value":"plain text"}`,
+			want: &kgo.Record{Value: []byte("plain text")},
+		},
+		{
+			name: "json string and base64 header",
+			line: `{"key":"abc","key_encoding":"json","headers":[{"key":"raw","value":"/w==","value_encoding":"base64"}],"value":"abc","value_encoding":"text"}`,
+			want: &kgo.Record{Key: []byte(`"abc"`), Headers: []kgo.RecordHeader{{Key: "raw", Value: []byte{0xff}}}, Value: []byte("abc")},
+		},
+		{
+			name: "export line",
+			line: `{"first_name": "Ernie", "age": 42}`,
+			want: &kgo.Record{Value: []byte(`{"first_name":"Ernie","age":42}`)},
+		},
+		{name: "bad base64", line: `{"value":"%%%","value_encoding":"base64"}`, wantErr: true},
+		{name: "text not a string", line: `{"value":1,"value_encoding":"text"}`, wantErr: true},
+		{name: "unknown encoding", line: `{"value":"abc","value_encoding":"hex"}`, wantErr: true},
+		{name: "bad timestamp", line: `{"timestamp":"yesterday","value":1}`, wantErr: true},
+		{name: "not json", line: `key=value`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseJsonlRecord([]byte(tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSinkRecordRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 10, 19, 10, 10, 9, 349207201, time.UTC)
+	payloads := [][]byte{
+		nil,
+		{},
+		[]byte("abc"),
+		[]byte(`"abc"`),
+		[]byte(`{"a":1}`),
+		[]byte(`{ "a": 1 }`),
+		[]byte(`{"a":"<b>"}`),
+		[]byte("42"),
+		[]byte("tab\tand \"quotes\""),
+		{0x00, 0xff, 0xfe},
+		{'"', 0xff, '"'},
+	}
+	for _, p := range payloads {
+		rec := &kgo.Record{
+			Timestamp: ts,
+			Key:       p,
+			Headers:   []kgo.RecordHeader{{Key: "h", Value: p}},
+			Value:     p,
+		}
+		line, err := json.Marshal(newSinkRecord(rec))
+		if err != nil {
+			t.Fatalf("%q: %v", p, err)
+		}
+		got, err := parseJsonlRecord(line)
+		if err != nil {
+			t.Errorf("%q: %s: %v", p, line, err)
+			continue
+		}
+		if !bytes.Equal(got.Key, p) || !bytes.Equal(got.Value, p) || (got.Key == nil) != (p == nil) || (got.Value == nil) != (p == nil) {
+			t.Errorf("%q: %s: key %q, value %q", p, line, got.Key, got.Value)
+		}
+		if len(got.Headers) != 1 || !bytes.Equal(got.Headers[0].Value, p) || !got.Timestamp.Equal(ts) {
+			t.Errorf("%q: %s: headers %q, timestamp %s", p, line, got.Headers, got.Timestamp)
+		}
+	}
+}
+
+func TestParseKcatRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *kgo.Record
+		wantErr bool
+	}{
+		{
+			name: "full",
+			line: `{"topic":"orders","partition":0,"offset":7,"tstype":"create","ts":1760868609349,"broker":1,"headers":["source","web","trace","abc"],"key":"o-1","payload":"{\"id\":1}"}`,
+			want: &kgo.Record{
+				Timestamp: time.UnixMilli(1760868609349),
+				Key:       []byte("o-1"),
+				Headers:   []kgo.RecordHeader{{Key: "source", Value: []byte("web")}, {Key: "trace", Value: []byte("abc")}},
+				Value:     []byte(`{"id":1}`),
+			},
+		},
+		{
+			name: "null key and payload, no timestamp",
+			line: `{"topic":"orders","ts":-1,"key":null,"payload":null}`,
+			want: &kgo.Record{},
+		},
+		{
+			name: "odd headers",
+			line: `{"headers":["source"],"payload":"x"}`,
+			want: &kgo.Record{Value: []byte("x")},
+		},
+		{name: "not json", line: `% Reached end of topic`, wantErr: true},
+		{name: "wrong type", line: `{"ts":"now"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseKcatRecord([]byte(tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJsonlReader(t *testing.T) {
+	r := &jsonlReader{r: bufio.NewReader(strings.NewReader("{\"value\":1}\n\n   \nbroken\n{\"value\":2}")), parse: parseJsonlRecord}
+	var values []string
+	var recordErrs []int64
+	for {
+		rec, err := r.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var re *replayRecordError
+		if errors.As(err, &re) {
+			recordErrs = append(recordErrs, re.line)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		values = append(values, string(rec.Value))
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %q, want %q", values, want)
+	}
+	if want := []int64{2}; !reflect.DeepEqual(recordErrs, want) {
+		t.Errorf("record errors on lines %v, want %v", recordErrs, want)
+	}
+}
+
+func TestCsvReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []*kgo.Record
+		wantErr []int64 // records that fail
+	}{
+		{
+			name: "key, value, timestamp and headers",
+			file: "key,value,timestamp,header.source\n" +
+				"k1,v1,1760868609349,web\n" +
+				",\"a,b\",2026-10-19T10:10:09Z,\n",
+			want: []*kgo.Record{
+				{Key: []byte("k1"), Value: []byte("v1"), Timestamp: time.UnixMilli(1760868609349), Headers: []kgo.RecordHeader{{Key: "source", Value: []byte("web")}}},
+				{Value: []byte("a,b"), Timestamp: time.Date(2026, 10, 19, 10, 10, 9, 0, time.UTC), Headers: []kgo.RecordHeader{{Key: "source", Value: []byte{}}}},
+			},
+		},
+		{
+			name: "export columns",
+			file: "key,first_name,address.city,address.zip\n" +
+				"u1,Ernie,Seoul,04524\n" +
+				"u2,Bert\n",
+			want: []*kgo.Record{
+				{Key: []byte("u1"), Value: []byte(`{"address":{"city":"Seoul","zip":"04524"},"first_name":"Ernie"}`)},
+				{Key: []byte("u2"), Value: []byte(`{"first_name":"Bert"}`)},
+			},
+		},
+		{
+			name: "bad timestamp and quote",
+			file: "value,timestamp\n" +
+				"v1,yesterday\n" +
+				"\"v2,1\n",
+			wantErr: []int64{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		r, err := newCsvReader(strings.NewReader(tt.file))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []*kgo.Record
+		var recordErrs []int64
+		for {
+			rec, err := r.next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var re *replayRecordError
+			if errors.As(err, &re) {
+				recordErrs = append(recordErrs, re.line)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: next: %v", tt.name, err)
+			}
+			got = append(got, rec)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(recordErrs, tt.wantErr) {
+			t.Errorf("%s: record errors %v, want %v", tt.name, recordErrs, tt.wantErr)
+		}
+	}
+
+	if _, err := newCsvReader(strings.NewReader("")); err == nil {
+		t.Errorf("newCsvReader of an empty file: no error")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "", want: time.Time{}},
+		{in: "1760868609349", want: time.UnixMilli(1760868609349)},
+		{in: "0", want: time.UnixMilli(0)},
+		{in: "2026-10-19T10:10:09Z", want: time.Date(2026, 10, 19, 10, 10, 9, 0, time.UTC)},
+		{in: "2026-10-19T10:10:09.349207201Z", want: time.Date(2026, 10, 19, 10, 10, 9, 349207201, time.UTC)},
+		{in: "2026-10-19T19:10:09+09:00", want: time.Date(2026, 10, 19, 10, 10, 9, 0, time.UTC)},
+		{in: "2026-10-19", wantErr: true},
+		{in: "1.5", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimestamp(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("parseTimestamp(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReplayDueTimestamps(t *testing.T) {
+	ts := time.UnixMilli(1760868609349)
+	tests := []struct {
+		keep bool
+		want time.Time // zero is the produce time
+	}{
+		{keep: false, want: time.Time{}},
+		{keep: true, want: ts},
+	}
+	for _, tt := range tests {
+		s := &replaySource{cfg: config.ReplayConfig{Speed: 1, KeepTimestamps: tt.keep}}
+		rec := &kgo.Record{Timestamp: ts}
+		s.due(rec)
+		if !rec.Timestamp.Equal(tt.want) {
+			t.Errorf("keep-timestamps %v: timestamp %s, want %s", tt.keep, rec.Timestamp, tt.want)
+		}
+	}
+}
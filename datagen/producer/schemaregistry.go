@@ -0,0 +1,203 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/message"
+	"spitha/datagen/datagen/message/avro"
+	"spitha/datagen/datagen/message/jsonschema"
+	"spitha/datagen/datagen/message/protobuf"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/sr"
+)
+
+/**********************************************************************
+**                                                                   **
+**                      Schema registry client                       **
+**                                                                   **
+***********************************************************************/
+// newSchemaRegistryClient returns a client with the TLS, authentication,
+// headers and timeout of producer.schema-registry.server.
+func newSchemaRegistryClient(cp config.ProducerConfig) (*sr.Client, error) {
+	server := cp.SchemaRegistry.Server
+	var urls []string
+	for _, u := range strings.Split(server.Urls, ",") {
+		urls = append(urls, strings.TrimSpace(u))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if server.Tls.IsSet() {
+		tlsConfig, err := loadTlsConfig(server.Tls)
+		if err != nil {
+			return nil, fmt.Errorf("schema registry: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	transport.DialContext = (&net.Dialer{Timeout: time.Duration(server.Timeout), KeepAlive: 30 * time.Second}).DialContext
+
+	srOpts := []sr.ClientOpt{
+		sr.URLs(urls...),
+		sr.HTTPClient(&http.Client{Timeout: time.Duration(server.Timeout), Transport: transport}),
+	}
+
+	// authentication
+	switch {
+	case server.Username != "" && server.Password != "":
+		srOpts = append(srOpts, sr.BasicAuth(server.Username, server.Password))
+	case server.Token != "":
+		srOpts = append(srOpts, sr.BearerToken(server.Token))
+	}
+	var preReqs []func(req *http.Request) error
+	if server.Oauth.IsSet() {
+		tokens, err := oauthTokenSource(context.Background(), server.Oauth)
+		if err != nil {
+			return nil, fmt.Errorf("schema registry oauth: %w", err)
+		}
+		preReqs = append(preReqs, func(req *http.Request) error {
+			token, err := tokens.Token()
+			if err != nil {
+				return fmt.Errorf("failed to refresh schema registry token : %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			return nil
+		})
+	}
+
+	// headers, e.g. target-sr-cluster of Confluent Cloud
+	if len(server.Headers) > 0 {
+		preReqs = append(preReqs, func(req *http.Request) error {
+			for name, v := range server.Headers {
+				req.Header.Set(name, v)
+			}
+			return nil
+		})
+	}
+	if len(preReqs) > 0 {
+		srOpts = append(srOpts, sr.PreReq(func(req *http.Request) error {
+			for _, preReq := range preReqs {
+				if err := preReq(req); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+	return sr.NewClient(srOpts...)
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Schema registry                          **
+**                                                                   **
+***********************************************************************/
+var schemaTypes = map[string]sr.SchemaType{
+	value.SCHEMA_REGISTRY_MEESAGE_TYPE_AVRO:      sr.TypeAvro,
+	value.SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF: sr.TypeProtobuf,
+	value.SCHEMA_REGISTRY_MEESAGE_TYPE_JSON:      sr.TypeJSON,
+}
+
+// registerSchema registers the schema of the message mode, or looks up the
+// pre-registered one, and sets the serde of dp.
+func registerSchema(config *config.ConfigConfig, dp *datagenProducer) error {
+	srConfig := config.Producer.SchemaRegistry
+	if srConfig.Server.Urls == "" {
+		return nil
+	}
+	dp.SRMessageType = srConfig.Type
+	logger.Log.Info("use schema registry")
+	schemaType, ok := schemaTypes[srConfig.Type]
+	if !ok {
+		return fmt.Errorf("only the (avro, protobuf, json) type is supported: %q", srConfig.Type)
+	}
+
+	// create client
+	srClient, err := newSchemaRegistryClient(config.Producer)
+	if err != nil {
+		return err
+	}
+
+	// the schema of the generated values
+	sample := message.Sample(config.Datagen.Message.Mode, config.Datagen.Message.QuickStart, srConfig.Type)
+	var schemaText string
+	switch srConfig.Type {
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_AVRO:
+		schemaText, err = avro.Schema(sample)
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF:
+		schemaText, err = protobuf.Schema(sample)
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_JSON:
+		schemaText, err = jsonschema.Schema(sample)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	schema := sr.Schema{Schema: schemaText, Type: schemaType}
+	var id int
+	switch {
+	case srConfig.SchemaId > 0:
+		// pre-registered by id
+		if schema, err = srClient.SchemaByID(ctx, srConfig.SchemaId); err != nil {
+			return fmt.Errorf("schema id %d: %w", srConfig.SchemaId, err)
+		}
+		id = srConfig.SchemaId
+		logger.Log.Info(fmt.Sprintf("using schema id %d", id))
+	case srConfig.Version != "":
+		// pre-registered by subject version
+		version := -1
+		if srConfig.Version != value.SCHEMA_REGISTRY_VERSION_LATEST {
+			version, _ = strconv.Atoi(srConfig.Version)
+		}
+		ss, err := srClient.SchemaByVersion(ctx, srConfig.Subject, version)
+		if err != nil {
+			return fmt.Errorf("schema subject %q version %s: %w", srConfig.Subject, srConfig.Version, err)
+		}
+		schema, id = ss.Schema, ss.ID
+		logger.Log.Info(fmt.Sprintf("using schema subject %q version %d id %d", ss.Subject, ss.Version, ss.ID))
+	default:
+		ss, err := srClient.CreateSchema(ctx, srConfig.Subject, schema)
+		if err != nil {
+			return fmt.Errorf("schema subject %q: %w", srConfig.Subject, err)
+		}
+		id = ss.ID
+		logger.Log.Info(fmt.Sprintf("created or reusing schema subject %q version %d id %d", ss.Subject, ss.Version, ss.ID))
+	}
+	if schema.Type != schemaType {
+		return fmt.Errorf("schema id %d is a %s schema, producer.schema-registry.type is %s", id, schema.Type, srConfig.Type)
+	}
+	// a pre-registered schema must fit the generated values
+	if srConfig.SchemaId > 0 || srConfig.Version != "" {
+		switch srConfig.Type {
+		case value.SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF:
+			err = protobuf.Check(schema.Schema, sample)
+		case value.SCHEMA_REGISTRY_MEESAGE_TYPE_JSON:
+			err = jsonschema.Check(schema.Schema, sample)
+		}
+		if err != nil {
+			return fmt.Errorf("schema id %d: %w", id, err)
+		}
+	}
+
+	// serde register
+	serde := &sr.Serde{}
+	switch srConfig.Type {
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_AVRO:
+		if err := avro.Register(serde, id, schema.Schema, sample); err != nil {
+			return err
+		}
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF:
+		protobuf.Register(serde, id, sample)
+	case value.SCHEMA_REGISTRY_MEESAGE_TYPE_JSON:
+		jsonschema.Register(serde, id, sample)
+	}
+	dp.SchemaRegistry.Serde = serde
+	return nil
+}
@@ -0,0 +1,308 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                            Record sink                            **
+**                                                                   **
+***********************************************************************/
+// recordSink is where a worker sends its records: the clients of every
+// cluster, or a writer of a dry run. It is used by a single worker.
+type recordSink interface {
+	Produce(ctx context.Context, rec *kgo.Record, promise func(*kgo.Record, error))
+	Flush(ctx context.Context) error
+	AbortBufferedRecords(ctx context.Context) error
+	BeginTransaction() error
+	EndTransaction(ctx context.Context, commit kgo.TransactionEndTry) error
+	EndAndBeginTransaction(ctx context.Context, how kgo.EndBeginTxnHow, commit kgo.TransactionEndTry, onEnd func(context.Context, error) error) error
+	Close()
+	setSequence(on bool)
+}
+
+// sequencer tags the records of a worker with value.HEADER_SEQ.
+type sequencer struct {
+	worker int
+	on     bool
+	seq    uint64 // records produced by the worker
+}
+
+func (s *sequencer) setSequence(on bool) {
+	s.on = on
+}
+
+func (s *sequencer) stamp(rec *kgo.Record) {
+	if !s.on {
+		return
+	}
+	s.seq++
+	rec.Headers = append(rec.Headers, kgo.RecordHeader{
+		Key:   value.HEADER_SEQ,
+		Value: []byte(strconv.Itoa(s.worker) + "-" + strconv.FormatUint(s.seq, 10)),
+	})
+}
+
+/**********************************************************************
+**                                                                   **
+**                           Dry-run sink                            **
+**                                                                   **
+***********************************************************************/
+// SINK_FLUSH_INTERVAL is how often the records written to a file sink are
+// flushed. The stdout sink is flushed after every record, so it can be piped.
+const SINK_FLUSH_INTERVAL = time.Second
+
+// recordWriter writes the records of every worker to stdout or a file, one
+// record at a time.
+type recordWriter struct {
+	mu         sync.Mutex
+	w          *bufio.Writer
+	file       *os.File // nil for stdout
+	format     string
+	topic      string
+	partitions int
+	done       chan struct{} // closed to end the flush ticker of a file
+}
+
+// openRecordWriter opens the sink of a dry run, nil when records go to Kafka.
+// A file is appended to and flushed every SINK_FLUSH_INTERVAL.
+func openRecordWriter(cfg *config.ConfigConfig) (*recordWriter, error) {
+	cs := cfg.Sink
+	rw := &recordWriter{format: cs.Format, topic: cfg.Topic.Name, partitions: cfg.Topic.Partition}
+	switch cs.Type {
+	case value.SINK_STDOUT:
+		rw.w = bufio.NewWriter(os.Stdout)
+	case value.SINK_FILE:
+		f, err := os.OpenFile(cs.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("sink: %w", err)
+		}
+		rw.file, rw.w = f, bufio.NewWriter(f)
+		rw.done = make(chan struct{})
+		go rw.flushTicker(time.NewTicker(SINK_FLUSH_INTERVAL), rw.done)
+	default:
+		return nil, nil
+	}
+	return rw, nil
+}
+
+func (rw *recordWriter) write(rec *kgo.Record) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	var err error
+	if rw.format == value.SINK_FORMAT_PRETTY {
+		err = writePretty(rw.w, rec)
+	} else {
+		var line []byte
+		if line, err = json.Marshal(newSinkRecord(rec)); err == nil {
+			_, err = rw.w.Write(append(line, '\n'))
+		}
+	}
+	if err == nil && rw.file == nil {
+		err = rw.w.Flush()
+	}
+	return err
+}
+
+func (rw *recordWriter) flushTicker(ticker *time.Ticker, done <-chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := rw.flush(); err != nil {
+				logger.Log.Error(fmt.Sprintln("sink : ", err))
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (rw *recordWriter) flush() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.w.Flush()
+}
+
+// Close flushes the records and closes the file.
+func (rw *recordWriter) Close() error {
+	if rw == nil {
+		return nil
+	}
+	if rw.done != nil {
+		close(rw.done)
+		rw.done = nil
+	}
+	err := rw.flush()
+	if rw.file != nil {
+		if cerr := rw.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// writerSink is the recordSink of a worker in a dry run. Records get the
+// partition the default partitioner of the client would pick for their key;
+// records without a key are spread round robin. Transactions are not used.
+type writerSink struct {
+	sequencer
+	out     *recordWriter
+	keyed   kgo.TopicPartitioner
+	keyless kgo.TopicPartitioner
+}
+
+func newWriterSink(out *recordWriter, worker int) *writerSink {
+	return &writerSink{
+		sequencer: sequencer{worker: worker},
+		out:       out,
+		keyed:     kgo.StickyKeyPartitioner(nil).ForTopic(out.topic),
+		keyless:   kgo.RoundRobinPartitioner().ForTopic(out.topic),
+	}
+}
+
+func (s *writerSink) Produce(_ context.Context, rec *kgo.Record, promise func(*kgo.Record, error)) {
+	s.stamp(rec)
+	if rec.Topic == "" {
+		rec.Topic = s.out.topic
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now()
+	}
+	if rec.Key != nil {
+		rec.Partition = int32(s.keyed.Partition(rec, s.out.partitions))
+	} else {
+		rec.Partition = int32(s.keyless.Partition(rec, s.out.partitions))
+	}
+	promise(rec, s.out.write(rec))
+}
+
+func (s *writerSink) Flush(context.Context) error                { return s.out.flush() }
+func (s *writerSink) AbortBufferedRecords(context.Context) error { return nil }
+func (s *writerSink) BeginTransaction() error                    { return nil }
+func (s *writerSink) EndTransaction(context.Context, kgo.TransactionEndTry) error {
+	return nil
+}
+func (s *writerSink) EndAndBeginTransaction(ctx context.Context, _ kgo.EndBeginTxnHow, _ kgo.TransactionEndTry, onEnd func(context.Context, error) error) error {
+	return onEnd(ctx, nil)
+}
+func (s *writerSink) Close() {}
+
+/**********************************************************************
+**                                                                   **
+**                          Record formats                           **
+**                                                                   **
+***********************************************************************/
+// sinkRecord is a record as a JSON line. The encoding of a key or value tells
+// how to read its bytes back: ENCODING_JSON for JSON kept as is, ENCODING_TEXT
+// for a string of other UTF-8 and ENCODING_BASE64 for anything else.
+type sinkRecord struct {
+	Topic         string          `json:"topic"`
+	Partition     int32           `json:"partition"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Key           json.RawMessage `json:"key"`
+	KeyEncoding   string          `json:"key_encoding,omitempty"`
+	Headers       []sinkHeader    `json:"headers"`
+	Value         json.RawMessage `json:"value"`
+	ValueEncoding string          `json:"value_encoding,omitempty"`
+}
+
+type sinkHeader struct {
+	Key           string `json:"key"`
+	Value         string `json:"value"`
+	ValueEncoding string `json:"value_encoding,omitempty"` // ENCODING_BASE64 when not UTF-8
+}
+
+// Encodings of the key, value and header values of a sinkRecord.
+const (
+	ENCODING_JSON   = "json"
+	ENCODING_TEXT   = "text"
+	ENCODING_BASE64 = "base64"
+)
+
+func newSinkRecord(rec *kgo.Record) sinkRecord {
+	sr := sinkRecord{
+		Topic:     rec.Topic,
+		Partition: rec.Partition,
+		Timestamp: rec.Timestamp,
+		Headers:   []sinkHeader{},
+	}
+	sr.Key, sr.KeyEncoding = encodeBytes(rec.Key)
+	sr.Value, sr.ValueEncoding = encodeBytes(rec.Value)
+	for _, h := range rec.Headers {
+		header := sinkHeader{Key: h.Key, Value: string(h.Value)}
+		if !utf8.Valid(h.Value) {
+			header.Value, header.ValueEncoding = base64.StdEncoding.EncodeToString(h.Value), ENCODING_BASE64
+		}
+		sr.Headers = append(sr.Headers, header)
+	}
+	return sr
+}
+
+// encodeBytes returns b as JSON and its encoding. JSON is only kept as is when
+// it is written unchanged, so "abc" with its quotes and abc stay apart and
+// replay reads back the same bytes.
+func encodeBytes(b []byte) (json.RawMessage, string) {
+	if b == nil {
+		return json.RawMessage("null"), ""
+	}
+	if json.Valid(b) {
+		if out, err := json.Marshal(json.RawMessage(b)); err == nil && bytes.Equal(out, b) {
+			return json.RawMessage(b), ENCODING_JSON
+		}
+	}
+	if utf8.Valid(b) {
+		s, _ := json.Marshal(string(b))
+		return s, ENCODING_TEXT
+	}
+	s, _ := json.Marshal(base64.StdEncoding.EncodeToString(b))
+	return s, ENCODING_BASE64
+}
+
+// writePretty writes rec as an indented block for reading in a terminal.
+func writePretty(w io.Writer, rec *kgo.Record) error {
+	sr := newSinkRecord(rec)
+	var b strings.Builder
+	fmt.Fprintf(&b, "topic %s, partition %d, timestamp %s\n", sr.Topic, sr.Partition, sr.Timestamp.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "key     : %s%s\n", indentJSON(sr.Key), encodingNote(sr.KeyEncoding))
+	for _, h := range sr.Headers {
+		fmt.Fprintf(&b, "header  : %s=%s%s\n", h.Key, h.Value, encodingNote(h.ValueEncoding))
+	}
+	fmt.Fprintf(&b, "value   : %s%s\n\n", indentJSON(sr.Value), encodingNote(sr.ValueEncoding))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func indentJSON(raw json.RawMessage) string {
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return string(raw)
+	}
+	return string(out)
+}
+
+// encodingNote marks the values that are not shown as they are.
+func encodingNote(encoding string) string {
+	if encoding != ENCODING_BASE64 {
+		return ""
+	}
+	return " (" + encoding + ")"
+}
@@ -0,0 +1,264 @@
+package producer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"spitha/datagen/datagen/config"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+/**********************************************************************
+**                                                                   **
+**                                Tls                                **
+**                                                                   **
+***********************************************************************/
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadTlsConfig builds the TLS config of a connection. Mutual TLS is used
+// whenever a client certificate is given, independently of the CA.
+func loadTlsConfig(tc config.TlsConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: tc.SkipVerify,
+		ServerName:         tc.ServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if tc.MinVersion != "" {
+		tlsConfig.MinVersion = tlsVersions[tc.MinVersion]
+	}
+	for _, name := range tc.CipherSuites {
+		suite := config.CipherSuite(name)
+		if suite == nil {
+			return nil, fmt.Errorf("tls: unknown cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, suite.ID)
+	}
+
+	// CA, the system roots when nil
+	rootCAs, err := loadRootCAs(tc)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	// Mutual Tls
+	cert, err := loadClientCert(tc)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
+	return tlsConfig, nil
+}
+
+/**********************************************************************
+**                                                                   **
+**                               Tls CA                              **
+**                                                                   **
+***********************************************************************/
+func loadRootCAs(tc config.TlsConfig) (*x509.CertPool, error) {
+	var certs []*x509.Certificate
+	var err error
+	switch {
+	case tc.Cafile != "":
+		var caCert []byte
+		if caCert, err = os.ReadFile(tc.Cafile); err != nil {
+			return nil, fmt.Errorf("tls cafile: %w", err)
+		}
+		if certs, err = parsePEMCerts(caCert); err != nil {
+			return nil, fmt.Errorf("tls cafile %s: %w", tc.Cafile, err)
+		}
+	case tc.Ca != "":
+		if certs, err = parsePEMCerts([]byte(tc.Ca)); err != nil {
+			return nil, fmt.Errorf("tls ca: %w", err)
+		}
+	case tc.Truststore.Path != "":
+		if certs, err = loadTruststore(tc.Truststore); err != nil {
+			return nil, fmt.Errorf("tls truststore %s: %w", tc.Truststore.Path, err)
+		}
+	default:
+		return nil, nil
+	}
+
+	caCertPool := x509.NewCertPool()
+	for _, cert := range certs {
+		caCertPool.AddCert(cert)
+	}
+	return caCertPool, nil
+}
+
+func loadTruststore(store config.StoreConfig) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(store.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch store.StoreType() {
+	case "pkcs12":
+		certs, err := pkcs12.DecodeTrustStore(data, store.Password)
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("no trusted certificate found")
+		}
+		return certs, nil
+	case "jks":
+		ks, err := loadJKS(data, store.Password)
+		if err != nil {
+			return nil, err
+		}
+		var certs []*x509.Certificate
+		for _, alias := range ks.Aliases() {
+			if !ks.IsTrustedCertificateEntry(alias) {
+				continue
+			}
+			entry, err := ks.GetTrustedCertificateEntry(alias)
+			if err != nil {
+				return nil, fmt.Errorf("alias %s: %w", alias, err)
+			}
+			cert, err := x509.ParseCertificate(entry.Certificate.Content)
+			if err != nil {
+				return nil, fmt.Errorf("alias %s: %w", alias, err)
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("no trusted certificate entry found")
+		}
+		return certs, nil
+	}
+	return nil, fmt.Errorf("unknown store type %q", store.Type)
+}
+
+/**********************************************************************
+**                                                                   **
+**                         Tls client certificate                    **
+**                                                                   **
+***********************************************************************/
+// loadClientCert returns nil without a client certificate.
+func loadClientCert(tc config.TlsConfig) (*tls.Certificate, error) {
+	switch {
+	case tc.Certfile != "":
+		cert, err := tls.LoadX509KeyPair(tc.Certfile, tc.Keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("tls certfile %s / keyfile %s: %w", tc.Certfile, tc.Keyfile, err)
+		}
+		return &cert, nil
+	case tc.Cert != "":
+		cert, err := tls.X509KeyPair([]byte(tc.Cert), []byte(tc.Key))
+		if err != nil {
+			return nil, fmt.Errorf("tls cert / key: %w", err)
+		}
+		return &cert, nil
+	case tc.Keystore.Path != "":
+		cert, err := loadKeystore(tc.Keystore)
+		if err != nil {
+			return nil, fmt.Errorf("tls keystore %s: %w", tc.Keystore.Path, err)
+		}
+		return cert, nil
+	}
+	return nil, nil
+}
+
+func loadKeystore(store config.StoreConfig) (*tls.Certificate, error) {
+	data, err := os.ReadFile(store.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch store.StoreType() {
+	case "pkcs12":
+		key, leaf, caCerts, err := pkcs12.DecodeChain(data, store.Password)
+		if err != nil {
+			return nil, err
+		}
+		cert := &tls.Certificate{PrivateKey: key, Leaf: leaf, Certificate: [][]byte{leaf.Raw}}
+		for _, ca := range caCerts {
+			cert.Certificate = append(cert.Certificate, ca.Raw)
+		}
+		return cert, nil
+	case "jks":
+		ks, err := loadJKS(data, store.Password)
+		if err != nil {
+			return nil, err
+		}
+		keyPassword := store.KeyPassword
+		if keyPassword == "" {
+			keyPassword = store.Password
+		}
+		// the first private key entry in alias order
+		for _, alias := range ks.Aliases() {
+			if !ks.IsPrivateKeyEntry(alias) {
+				continue
+			}
+			entry, err := ks.GetPrivateKeyEntry(alias, []byte(keyPassword))
+			if err != nil {
+				return nil, fmt.Errorf("alias %s: %w", alias, err)
+			}
+			key, err := x509.ParsePKCS8PrivateKey(entry.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("alias %s: %w", alias, err)
+			}
+			cert := &tls.Certificate{PrivateKey: key}
+			for _, c := range entry.CertificateChain {
+				cert.Certificate = append(cert.Certificate, c.Content)
+			}
+			if len(cert.Certificate) == 0 {
+				return nil, fmt.Errorf("alias %s has no certificate chain", alias)
+			}
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return nil, fmt.Errorf("alias %s: %w", alias, err)
+			}
+			return cert, nil
+		}
+		return nil, fmt.Errorf("no private key entry found")
+	}
+	return nil, fmt.Errorf("unknown store type %q", store.Type)
+}
+
+/**********************************************************************
+**                                                                   **
+**                             Tls utils                             **
+**                                                                   **
+***********************************************************************/
+func loadJKS(data []byte, password string) (keystore.KeyStore, error) {
+	ks := keystore.New(keystore.WithOrderedAliases())
+	if err := ks.Load(bytes.NewReader(data), []byte(password)); err != nil {
+		return ks, err
+	}
+	return ks, nil
+}
+
+// parsePEMCerts parses every CERTIFICATE block and fails when there is none.
+func parsePEMCerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
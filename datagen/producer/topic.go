@@ -0,0 +1,454 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"spitha/datagen/datagen/config"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	TOPIC_RECREATE_TIMEOUT = 30 * time.Second // wait for a deleted topic to be gone
+	TOPIC_POLL_INTERVAL    = 250 * time.Millisecond
+)
+
+/**********************************************************************
+**                                                                   **
+**                            Check Topic                            **
+**                                                                   **
+***********************************************************************/
+// checkTopic creates the topic with topic.configs when it is missing. An existing
+// topic is kept, altered to topic.configs, checked against them or recreated,
+// following topic.if-exists, and truncated with topic.truncate-before-run.
+// A reload (startup false) never recreates nor truncates the topic.
+func checkTopic(ctx context.Context, opts []kgo.Opt, ct config.TopicConfig, startup bool) error {
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	adminClient := kadm.NewClient(client)
+	topicList, err := adminClient.ListTopics(ctx, ct.Name)
+	if err != nil {
+		return err
+	}
+
+	if topicList.Has(ct.Name) {
+		switch ct.IfExists {
+		case value.TOPIC_IF_EXISTS_ALTER:
+			err = alterTopicConfigs(ctx, adminClient, ct)
+		case value.TOPIC_IF_EXISTS_FAIL_ON_DIFF:
+			err = diffTopicConfigs(ctx, adminClient, ct)
+		case value.TOPIC_IF_EXISTS_RECREATE:
+			if !startup {
+				logger.Log.Info(fmt.Sprintln("topic.if-exists recreate only applies at startup, keeping topic : ", ct.Name))
+				return nil
+			}
+			if _, err := adminClient.DeleteTopic(ctx, ct.Name); err != nil {
+				return fmt.Errorf("delete topic %s: %w", ct.Name, err)
+			}
+			logger.Log.Info(fmt.Sprintln("deleted topic to recreate it : ", ct.Name))
+		}
+		if ct.IfExists != value.TOPIC_IF_EXISTS_RECREATE {
+			if err == nil && startup && ct.TruncateBeforeRun {
+				err = truncateTopic(ctx, adminClient, ct.Name)
+			}
+			if err == nil && startup {
+				err = reportTopic(ctx, adminClient, ct.Name)
+			}
+			return err
+		}
+	}
+
+	// no topic
+	deadline := time.Now().Add(TOPIC_RECREATE_TIMEOUT)
+	for {
+		_, err = adminClient.CreateTopic(ctx, int32(ct.Partition), int16(ct.Replicafactor), topicConfigs(ct), ct.Name)
+		// a deleted topic is removed in the background
+		if !errors.Is(err, kerr.TopicAlreadyExists) || time.Now().After(deadline) {
+			break
+		}
+		if err := sleepContext(ctx, TOPIC_POLL_INTERVAL); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("create topic %s: %w", ct.Name, err)
+	}
+	logger.Log.Info(fmt.Sprintln("created topic : ", ct.Name))
+
+	// leaders are elected in the background
+	detail, err := waitTopicReady(ctx, adminClient, ct.Name, ct.Partition, ct.ReadyTimeout.Duration())
+	if err != nil {
+		return err
+	}
+	logLeaders(detail)
+	return nil
+}
+
+// alterTopicConfigs sets the configs of topic.configs that differ on an existing
+// topic. Other configs keep their value.
+func alterTopicConfigs(ctx context.Context, adminClient *kadm.Client, ct config.TopicConfig) error {
+	if len(ct.Configs) == 0 {
+		return nil
+	}
+	described, err := describeTopicConfigs(ctx, adminClient, ct.Name)
+	if err != nil {
+		return err
+	}
+	diffs := topicConfigDiffs(ct.Configs, described)
+	if len(diffs) == 0 {
+		logger.Log.Info(fmt.Sprintln("topic configs already set : ", ct.Name))
+		return nil
+	}
+	var alters []kadm.AlterConfig
+	for _, d := range diffs {
+		v := d.Expected
+		alters = append(alters, kadm.AlterConfig{Op: kadm.SetConfig, Name: d.Name, Value: &v})
+	}
+	responses, err := adminClient.AlterTopicConfigs(ctx, alters, ct.Name)
+	if err != nil {
+		return fmt.Errorf("alter topic %s configs: %w", ct.Name, err)
+	}
+	for _, r := range responses {
+		if r.Err != nil {
+			return fmt.Errorf("alter topic %s configs: %w %s", ct.Name, r.Err, r.ErrMessage)
+		}
+	}
+	logger.Log.Info(fmt.Sprintln("altered topic configs : ", ct.Name, joinDiffs(diffs)))
+	return nil
+}
+
+// diffTopicConfigs fails when a config of topic.configs differs on the existing topic.
+func diffTopicConfigs(ctx context.Context, adminClient *kadm.Client, ct config.TopicConfig) error {
+	if len(ct.Configs) == 0 {
+		return nil
+	}
+	described, err := describeTopicConfigs(ctx, adminClient, ct.Name)
+	if err != nil {
+		return err
+	}
+	if diffs := topicConfigDiffs(ct.Configs, described); len(diffs) > 0 {
+		return fmt.Errorf("topic %s configs differ: %s", ct.Name, joinDiffs(diffs))
+	}
+	return nil
+}
+
+func describeTopicConfigs(ctx context.Context, adminClient *kadm.Client, topic string) ([]kadm.Config, error) {
+	configs, err := adminClient.DescribeTopicConfigs(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("describe topic %s configs: %w", topic, err)
+	}
+	rc, err := configs.On(topic, nil)
+	if err == nil {
+		err = rc.Err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("describe topic %s configs: %w", topic, err)
+	}
+	return rc.Configs, nil
+}
+
+// topicConfigDiff is a config of topic.configs whose value differs on the topic.
+type topicConfigDiff struct {
+	Name     string
+	Current  string
+	Expected string
+	Unknown  bool // the broker does not describe the config
+	Default  bool // the current value is not set on the topic but inherited from the broker
+}
+
+func (d topicConfigDiff) String() string {
+	switch {
+	case d.Unknown:
+		return fmt.Sprintf("%s is unknown", d.Name)
+	case d.Default:
+		return fmt.Sprintf("%s is %q (broker default), expected %q", d.Name, d.Current, d.Expected)
+	default:
+		return fmt.Sprintf("%s is %q, expected %q", d.Name, d.Current, d.Expected)
+	}
+}
+
+// topicConfigDiffs compares the desired configs with the described configs of a
+// topic, sorted by name. Only the value counts: a desired value equal to the
+// broker default is not a difference, even if the topic does not set it. A
+// sensitive config is hidden by the broker and cannot be compared.
+func topicConfigDiffs(desired map[string]string, described []kadm.Config) []topicConfigDiff {
+	current := make(map[string]kadm.Config, len(described))
+	for _, c := range described {
+		current[c.Key] = c
+	}
+	var diffs []topicConfigDiff
+	for _, name := range sortedKeys(desired) {
+		c, ok := current[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, topicConfigDiff{Name: name, Expected: desired[name], Unknown: true})
+		case c.Sensitive:
+		case c.MaybeValue() != desired[name]:
+			diffs = append(diffs, topicConfigDiff{
+				Name:     name,
+				Current:  c.MaybeValue(),
+				Expected: desired[name],
+				Default:  c.Source != kmsg.ConfigSourceDynamicTopicConfig,
+			})
+		}
+	}
+	return diffs
+}
+
+func joinDiffs(diffs []topicConfigDiff) string {
+	s := make([]string, len(diffs))
+	for i, d := range diffs {
+		s[i] = d.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Topic readiness                          **
+**                                                                   **
+***********************************************************************/
+// waitTopicReady polls the metadata until the topic has at least partitions
+// partitions, each with a leader and every replica in sync.
+func waitTopicReady(ctx context.Context, adminClient *kadm.Client, topic string, partitions int, timeout time.Duration) (kadm.TopicDetail, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var problems []string
+	for {
+		detail, err := describeTopic(ctx, adminClient, topic)
+		if err == nil {
+			if problems = topicProblems(detail, partitions); len(problems) == 0 {
+				return detail, nil
+			}
+		} else {
+			problems = []string{err.Error()}
+		}
+		if err := sleepContext(ctx, TOPIC_POLL_INTERVAL); err != nil {
+			return detail, fmt.Errorf("topic %s not ready after %s: %s", topic, timeout, strings.Join(problems, ", "))
+		}
+	}
+}
+
+// reportTopic logs the leaders of an existing topic and warns about partitions
+// that are not ready; the run starts anyway.
+func reportTopic(ctx context.Context, adminClient *kadm.Client, topic string) error {
+	detail, err := describeTopic(ctx, adminClient, topic)
+	if err != nil {
+		return err
+	}
+	for _, problem := range topicProblems(detail, 0) {
+		logger.Log.Warn(fmt.Sprintf("topic %s : %s", topic, problem))
+	}
+	logLeaders(detail)
+	return nil
+}
+
+func describeTopic(ctx context.Context, adminClient *kadm.Client, topic string) (kadm.TopicDetail, error) {
+	metadata, err := adminClient.Metadata(ctx, topic)
+	if err != nil {
+		return kadm.TopicDetail{}, fmt.Errorf("metadata of topic %s: %w", topic, err)
+	}
+	detail, ok := metadata.Topics[topic]
+	switch {
+	case !ok:
+		return detail, fmt.Errorf("topic %s is missing from the metadata", topic)
+	case detail.Err != nil:
+		return detail, fmt.Errorf("metadata of topic %s: %w", topic, detail.Err)
+	}
+	return detail, nil
+}
+
+// topicProblems lists every partition without a leader or with replicas out of sync.
+func topicProblems(detail kadm.TopicDetail, partitions int) []string {
+	var problems []string
+	if len(detail.Partitions) < partitions {
+		problems = append(problems, fmt.Sprintf("%d of %d partitions", len(detail.Partitions), partitions))
+	}
+	for _, p := range detail.Partitions.Sorted() {
+		switch {
+		case p.Err != nil:
+			problems = append(problems, fmt.Sprintf("partition %d: %s", p.Partition, p.Err))
+		case p.Leader < 0:
+			problems = append(problems, fmt.Sprintf("partition %d has no leader", p.Partition))
+		case len(p.ISR) < len(p.Replicas):
+			problems = append(problems, fmt.Sprintf("partition %d has %d of %d replicas in sync", p.Partition, len(p.ISR), len(p.Replicas)))
+		}
+	}
+	return problems
+}
+
+// logLeaders logs how many partition leaders each broker holds.
+func logLeaders(detail kadm.TopicDetail) {
+	leaders := make(map[int32]int)
+	for _, p := range detail.Partitions {
+		if p.Leader >= 0 {
+			leaders[p.Leader]++
+		}
+	}
+	brokers := make([]int32, 0, len(leaders))
+	for broker := range leaders {
+		brokers = append(brokers, broker)
+	}
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i] < brokers[j] })
+	var counts []string
+	for _, broker := range brokers {
+		counts = append(counts, fmt.Sprintf("broker %d = %d", broker, leaders[broker]))
+	}
+	logger.Log.Info(fmt.Sprintf("partition leaders of topic %s (%d partitions) : %s", detail.Topic, len(detail.Partitions), strings.Join(counts, ", ")))
+}
+
+/**********************************************************************
+**                                                                   **
+**                          Topic lifecycle                          **
+**                                                                   **
+***********************************************************************/
+// truncateTopic deletes every record of the topic up to the high watermark.
+func truncateTopic(ctx context.Context, adminClient *kadm.Client, topic string) error {
+	ends, err := adminClient.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("list end offsets of topic %s: %w", topic, err)
+	}
+	responses, err := adminClient.DeleteRecords(ctx, ends.Offsets())
+	if err == nil {
+		err = responses.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("delete records of topic %s: %w", topic, err)
+	}
+	logger.Log.Info(fmt.Sprintln("deleted the records of topic : ", topic))
+	return nil
+}
+
+// deleteTopic deletes the topic once datagen stopped.
+func deleteTopic(ctx context.Context, opts []kgo.Opt, topic string) error {
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := kadm.NewClient(client).DeleteTopic(ctx, topic); err != nil {
+		return fmt.Errorf("delete topic %s: %w", topic, err)
+	}
+	logger.Log.Info(fmt.Sprintln("deleted topic : ", topic))
+	return nil
+}
+
+// expandTopic raises the partition count of the topic to partitions on every
+// cluster and makes the running workers pick up the new partitions right away.
+// g.mu is only held to read the clusters and to refresh the workers, so a reload,
+// the control api or Stop never wait on the topic becoming ready; Stop ends the
+// expansion before the next cluster.
+func (g *generator) expandTopic(topic string, partitions int) {
+	g.mu.Lock()
+	if g.stopping {
+		g.mu.Unlock()
+		return
+	}
+	targets, timeout := g.targets, g.config.Topic.ReadyTimeout.Duration()
+	g.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(g.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-g.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for _, t := range targets {
+		err := expandPartitions(ctx, t.opts, topic, partitions, timeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Log.Error(fmt.Sprintln("expand topic : ", t.named(targets, err)))
+			continue
+		}
+		// verify runs on a single cluster
+		g.producer.Load().Transaction.Verifier.expand(partitions)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, w := range g.workers {
+		if c := w.client.Load(); c != nil {
+			c.ForceMetadataRefresh()
+		}
+	}
+}
+
+func expandPartitions(ctx context.Context, opts []kgo.Opt, topic string, partitions int, timeout time.Duration) error {
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	adminClient := kadm.NewClient(client)
+	responses, err := adminClient.UpdatePartitions(ctx, partitions, topic)
+	if err == nil {
+		err = responses.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", topic, err)
+	}
+	logger.Log.Info(fmt.Sprintf("expanded topic %s to %d partitions", topic, partitions))
+	detail, err := waitTopicReady(ctx, adminClient, topic, partitions, timeout)
+	if err != nil {
+		return err
+	}
+	logLeaders(detail)
+	return nil
+}
+
+/**********************************************************************
+**                                                                   **
+**                            Topic utils                            **
+**                                                                   **
+***********************************************************************/
+func topicConfigs(ct config.TopicConfig) map[string]*string {
+	if len(ct.Configs) == 0 {
+		return nil
+	}
+	configs := make(map[string]*string, len(ct.Configs))
+	for name, v := range ct.Configs {
+		v := v
+		configs[name] = &v
+	}
+	return configs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
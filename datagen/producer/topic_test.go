@@ -0,0 +1,64 @@
+package producer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestTopicConfigDiffs(t *testing.T) {
+	str := func(s string) *string { return &s }
+	described := []kadm.Config{
+		{Key: "retention.ms", Value: str("3600000"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+		{Key: "cleanup.policy", Value: str("delete"), Source: kmsg.ConfigSourceDefaultConfig},
+		{Key: "min.insync.replicas", Value: str("2"), Source: kmsg.ConfigSourceStaticBrokerConfig},
+		{Key: "segment.bytes", Value: str("1073741824"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+		{Key: "sasl.jaas.config", Sensitive: true, Source: kmsg.ConfigSourceDynamicTopicConfig},
+	}
+	tests := []struct {
+		name    string
+		desired map[string]string
+		want    []topicConfigDiff
+	}{
+		{name: "none"},
+		{
+			name:    "explicitly set and equal",
+			desired: map[string]string{"retention.ms": "3600000", "segment.bytes": "1073741824"},
+		},
+		{
+			name:    "broker default and equal",
+			desired: map[string]string{"cleanup.policy": "delete", "min.insync.replicas": "2"},
+		},
+		{
+			name:    "explicitly set and different",
+			desired: map[string]string{"retention.ms": "60000"},
+			want:    []topicConfigDiff{{Name: "retention.ms", Current: "3600000", Expected: "60000"}},
+		},
+		{
+			name:    "broker default and different",
+			desired: map[string]string{"cleanup.policy": "compact", "min.insync.replicas": "1"},
+			want: []topicConfigDiff{
+				{Name: "cleanup.policy", Current: "delete", Expected: "compact", Default: true},
+				{Name: "min.insync.replicas", Current: "2", Expected: "1", Default: true},
+			},
+		},
+		{
+			name:    "unknown and sensitive",
+			desired: map[string]string{"retention.mss": "1", "sasl.jaas.config": "secret"},
+			want:    []topicConfigDiff{{Name: "retention.mss", Expected: "1", Unknown: true}},
+		},
+	}
+	for _, tt := range tests {
+		if got := topicConfigDiffs(tt.desired, described); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	diffs := topicConfigDiffs(map[string]string{"cleanup.policy": "compact", "retention.ms": "60000", "retention.mss": "1"}, described)
+	want := `cleanup.policy is "delete" (broker default), expected "compact", retention.ms is "3600000", expected "60000", retention.mss is unknown`
+	if got := joinDiffs(diffs); got != want {
+		t.Errorf("joinDiffs = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                      Worker transaction state                     **
+**                                                                   **
+***********************************************************************/
+// txnState tracks the transaction a single worker currently has open.
+// Every worker owns its own state since datagenProducer is shared.
+type txnState struct {
+	id       string    // transactional id of the worker
+	seq      uint64    // sequence number of the open transaction
+	records  int64     // records produced in the open transaction
+	beganAt  time.Time // when the open transaction began
+	verifier *txnVerifier
+
+	// transaction sizing, zero means unlimited
+	maxRecords  int64
+	maxDuration time.Duration
+	abortRate   float64
+}
+
+func newTxnState(transactionId string) *txnState {
+	return &txnState{
+		id:      transactionId,
+		beganAt: time.Now(),
+	}
+}
+
+// configure applies the transaction settings of ds. Workers call it every time
+// they (re)enter a produce loop, so sizing follows config reloads.
+func (ts *txnState) configure(ds *datagenProducer) {
+	if ts == nil {
+		return
+	}
+	ts.maxRecords = ds.Transaction.MaxRecords
+	if ds.Produce.Mode == value.PRODUCE_MODE_INTERVAL && ts.maxRecords == 0 && ds.Transaction.MaxDuration == 0 {
+		ts.maxRecords = 1 // one record per transaction by default in interval mode
+	}
+	ts.maxDuration = ds.Transaction.MaxDuration
+	ts.abortRate = ds.Transaction.AbortRate
+	ts.verifier = ds.Transaction.Verifier
+}
+
+// begin marks the start of a new transaction.
+func (ts *txnState) begin() {
+	if ts == nil {
+		return
+	}
+	ts.beganAt = time.Now()
+}
+
+// stamp marks the record as part of the open transaction.
+func (ts *txnState) stamp(rec *kgo.Record) {
+	if ts == nil {
+		return
+	}
+	ts.records++
+	if ts.verifier == nil {
+		return
+	}
+	rec.Headers = append(rec.Headers,
+		kgo.RecordHeader{Key: value.HEADER_TXN_ID, Value: []byte(ts.id)},
+		kgo.RecordHeader{Key: value.HEADER_TXN_SEQ, Value: []byte(strconv.FormatUint(ts.seq, 10))},
+	)
+}
+
+// end reports the outcome of the open transaction and moves on to the next one.
+func (ts *txnState) end(committed bool) {
+	if ts == nil {
+		return
+	}
+	if ts.verifier != nil {
+		ts.verifier.report(ts.id, ts.seq, ts.records, committed)
+	}
+	ts.seq++
+	ts.records = 0
+	ts.beganAt = time.Now()
+}
+
+// sized reports whether transactions are bounded by record count or time
+// instead of the produce mode window.
+func (ts *txnState) sized() bool {
+	return ts != nil && (ts.maxRecords > 0 || ts.maxDuration > 0)
+}
+
+// due reports whether the open transaction reached its configured size.
+func (ts *txnState) due() bool {
+	if !ts.sized() || ts.records == 0 {
+		return false
+	}
+	if ts.maxRecords > 0 && ts.records >= ts.maxRecords {
+		return true
+	}
+	return ts.maxDuration > 0 && time.Since(ts.beganAt) >= ts.maxDuration
+}
+
+// injectAbort decides whether a clean transaction is deliberately aborted.
+func (ts *txnState) injectAbort() bool {
+	return ts != nil && ts.abortRate > 0 && rand.Float64() < ts.abortRate
+}
+
+/**********************************************************************
+**                                                                   **
+**                          End transaction                          **
+**                                                                   **
+***********************************************************************/
+// endTxn flushes and ends the open transaction without beginning the next one.
+// It commits unless a produce callback set needAbort.
+func endTxn(client recordSink, ctx context.Context, ts *txnState, needAbort *atomic.Bool) {
+	// Wait for all in-flight sends + callbacks to finish.
+	// Without Flush, some records may still be buffered and not part of this transaction.
+	if err := client.Flush(ctx); err != nil {
+		// If Flush fails, prefer the abort path.
+		needAbort.Store(true)
+		logger.Log.Error(fmt.Sprintln(err))
+	}
+
+	if needAbort.Load() {
+		// Remove any not-yet-sent records so they don't leak into the next transaction
+		_ = client.AbortBufferedRecords(ctx)
+		// Abort the current transaction
+		_ = client.EndTransaction(ctx, kgo.TryAbort)
+		ts.end(false)
+	} else if ts.injectAbort() {
+		// Deliberately abort a clean transaction
+		_ = client.EndTransaction(ctx, kgo.TryAbort)
+		ts.end(false)
+	} else {
+		// Try to commit; if it fails with an abortable state, immediately TryAbort
+		if err := client.EndTransaction(ctx, kgo.TryCommit); err != nil {
+			logger.Log.Error(fmt.Sprintln(err))
+			_ = client.EndTransaction(ctx, kgo.TryAbort)
+			ts.end(false)
+		} else {
+			ts.end(true)
+		}
+	}
+	needAbort.Store(false)
+}
+
+/**********************************************************************
+**                                                                   **
+**                     End and begin transaction                     **
+**                                                                   **
+***********************************************************************/
+// endAndBeginTxn ends the open transaction (commit if clean, else abort) and
+// begins the next one in a single call. EndAndBeginTransaction performs a Flush internally.
+func endAndBeginTxn(client recordSink, ctx context.Context, ts *txnState, failed bool) error {
+	endTry := kgo.TryCommit
+	if failed || ts.injectAbort() {
+		endTry = kgo.TryAbort
+	}
+
+	ended := false
+	err := client.EndAndBeginTransaction(
+		ctx,
+		kgo.EndBeginTxnSafe, // Safe mode: blocks new produces until end completes
+		endTry,              // Commit or Abort based on the transaction status
+		func(ctx context.Context, endErr error) error {
+			ended = true
+			ts.end(endErr == nil && endTry == kgo.TryCommit)
+			if endErr != nil {
+				// If ending failed, return the error so Begin is skipped.
+				logger.Log.Error(fmt.Sprintf("end txn err: %q", endErr))
+				return endErr
+			}
+			return nil // Proceed to BeginTransaction internally
+		},
+	)
+	if !ended {
+		ts.end(false)
+	}
+	return err
+}
@@ -1,14 +1,13 @@
 package producer
 
 import (
-	"context"
 	"fmt"
-	"spitha/datagen/datagen/config"
+	"os"
 	"spitha/datagen/datagen/logger"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
@@ -20,70 +19,6 @@ var (
 	MessageNumber uint64        = 0
 )
 
-/**********************************************************************
-**                                                                   **
-**                            Check Topic                            **
-**                                                                   **
-***********************************************************************/
-func checkTopic(ctx context.Context, opts []kgo.Opt, ct config.TopicConfig) error {
-	var adminClient *kadm.Client
-	client, err := kgo.NewClient(opts...)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-	adminClient = kadm.NewClient(client)
-	topicList, err := adminClient.ListTopics(ctx, ct.Name)
-	if err != nil {
-		return err
-	}
-
-	// no topic
-	if !topicList.Has(ct.Name) {
-		partition := int32(3)     // default partition
-		replicafactor := int16(1) // default replicafactor
-		if ct.Partition != "" {
-			partition = int32(stringToInt(ct.Partition))
-		}
-		if ct.Replicafactor != "" {
-			replicafactor = int16(stringToInt(ct.Replicafactor))
-		}
-		_, err := adminClient.CreateTopic(ctx, partition, replicafactor, nil, ct.Name)
-		if err != nil {
-			return err
-		}
-		time.Sleep(time.Second)
-	}
-	client.Close()
-
-	defer client.Close()
-	return nil
-}
-
-/**********************************************************************
-**                                                                   **
-**                            Type utils                             **
-**                                                                   **
-***********************************************************************/
-func stringToInt(value string) int {
-	i, err := strconv.Atoi(value)
-	if err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		return 0
-	}
-	return i
-}
-
-func stringToFloat64(value string) float64 {
-
-	i, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		logger.Log.Error(fmt.Sprintln(err))
-		return 0
-	}
-	return i
-}
-
 /**********************************************************************
 **                                                                   **
 **                            Metric print                           **
@@ -96,7 +31,7 @@ func metricTicker(ticker *time.Ticker) {
 			logger.Log.Info(fmt.Sprintln("max latency : ", MaxLatency))
 			logger.Log.Info(fmt.Sprintln("avg latency : ", TotalTime/time.Duration(MessageNumber)))
 			logger.Log.Info(fmt.Sprintln("number messages : ", MessageNumber))
-			fmt.Println()
+			fmt.Fprintln(os.Stderr) // stdout is left to the stdout sink
 
 			// init values
 			MinLatency = time.Duration(int64(1<<63 - 1))
@@ -107,6 +42,10 @@ func metricTicker(ticker *time.Ticker) {
 		} else {
 			logger.Log.Info(fmt.Sprintln("number messages : ", 0))
 		}
+		if consumed := consumedRecords.Swap(0); consumed != 0 {
+			logger.Log.Info(fmt.Sprintln("consumed messages : ", consumed))
+		}
+		logClusterMetrics()
 	}
 }
 
@@ -132,3 +71,82 @@ func checkElapsedLatency(elapsed time.Duration) {
 	// message number
 	MessageNumber++
 }
+
+/**********************************************************************
+**                                                                   **
+**                          Cluster metrics                          **
+**                                                                   **
+***********************************************************************/
+// clusterMetrics counts the acknowledged records of a single cluster since the
+// last metric print. They are only kept when clusters are configured.
+type clusterMetrics struct {
+	name    string
+	records atomic.Uint64
+	errors  atomic.Uint64
+	bytes   atomic.Uint64
+	latency atomic.Int64 // sum of the ack latencies in nanoseconds
+}
+
+var (
+	clusterMetricsMu sync.Mutex
+	clusterMetricsOf []*clusterMetrics // in registration order
+)
+
+// registerClusterMetrics returns the metrics of the cluster, kept across reloads.
+func registerClusterMetrics(name string) *clusterMetrics {
+	clusterMetricsMu.Lock()
+	defer clusterMetricsMu.Unlock()
+	for _, m := range clusterMetricsOf {
+		if m.name == name {
+			return m
+		}
+	}
+	m := &clusterMetrics{name: name}
+	clusterMetricsOf = append(clusterMetricsOf, m)
+	return m
+}
+
+// retainClusterMetrics drops the metrics of the clusters that are not in targets,
+// e.g. once a reload removed a cluster, so they are no longer logged.
+func retainClusterMetrics(targets []target) {
+	clusterMetricsMu.Lock()
+	defer clusterMetricsMu.Unlock()
+	var kept []*clusterMetrics
+	for _, m := range clusterMetricsOf {
+		for _, t := range targets {
+			if t.metrics == m {
+				kept = append(kept, m)
+				break
+			}
+		}
+	}
+	clusterMetricsOf = kept
+}
+
+// observe counts a record once the cluster acknowledged it or failed it.
+func (m *clusterMetrics) observe(r *kgo.Record, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+	m.records.Add(1)
+	m.bytes.Add(uint64(len(r.Key) + len(r.Value)))
+	m.latency.Add(int64(latency))
+}
+
+func logClusterMetrics() {
+	clusterMetricsMu.Lock()
+	defer clusterMetricsMu.Unlock()
+	for _, m := range clusterMetricsOf {
+		records, errors := m.records.Swap(0), m.errors.Swap(0)
+		bytes, latency := m.bytes.Swap(0), time.Duration(m.latency.Swap(0))
+		var avg time.Duration
+		if records > 0 {
+			avg = latency / time.Duration(records)
+		}
+		logger.Log.Info(fmt.Sprintf("cluster %s : %d messages, %d errors, %d bytes, avg ack latency %s", m.name, records, errors, bytes, avg))
+	}
+}
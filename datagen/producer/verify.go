@@ -0,0 +1,289 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"spitha/datagen/datagen/logger"
+	"spitha/datagen/datagen/value"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+/**********************************************************************
+**                                                                   **
+**                    Exactly-once verification                      **
+**                                                                   **
+***********************************************************************/
+// txnVerifier consumes the target topic with read_committed isolation and
+// cross-checks what is readable against the transaction outcomes reported
+// by the workers.
+type txnVerifier struct {
+	cancel  context.CancelFunc // ends consume and checkTicker
+	running sync.WaitGroup
+
+	mu          sync.Mutex
+	topic       string
+	consumer    partitionConsumer
+	partitions  int32 // partitions 0 to partitions-1 are consumed
+	gracePeriod time.Duration
+	outcomes    map[txnKey]*txnOutcome    // reported by workers
+	unreported  map[txnKey]*txnUnreported // records seen before the worker reported
+
+	verifiedTxns    uint64
+	verifiedRecords uint64
+	abortedVisible  uint64
+	missingRecords  uint64
+	duplicates      uint64
+}
+
+// partitionConsumer adds partitions to the consumer of the verifier.
+type partitionConsumer interface {
+	AddConsumePartitions(partitions map[string]map[int32]kgo.Offset)
+}
+
+type txnKey struct {
+	id  string
+	seq uint64
+}
+
+// txnUnreported counts the records of a transaction no worker reported yet. A
+// transaction of a previous run or another producer is never reported, so it
+// expires after the grace period.
+type txnUnreported struct {
+	records int64
+	seenAt  time.Time // first record
+}
+
+type txnOutcome struct {
+	committed  bool
+	records    int64
+	seen       int64
+	reportedAt time.Time
+}
+
+// startTxnVerifier starts consuming the topic from its current end offsets.
+// It must be called before the workers start producing.
+func startTxnVerifier(ctx context.Context, opts []kgo.Opt, topic string, gracePeriod time.Duration) (*txnVerifier, error) {
+	// start offsets
+	adminClient, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	endOffsets, err := kadm.NewClient(adminClient).ListEndOffsets(ctx, topic)
+	adminClient.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := endOffsets.Error(); err != nil {
+		return nil, err
+	}
+
+	// read committed consumer
+	consumerOpts := append([]kgo.Opt{}, opts...)
+	consumerOpts = append(consumerOpts,
+		kgo.ConsumePartitions(endOffsets.KOffsets()),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+	)
+	consumerClient, err := kgo.NewClient(consumerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	v := &txnVerifier{
+		cancel:      cancel,
+		topic:       topic,
+		consumer:    consumerClient,
+		partitions:  int32(len(endOffsets[topic])),
+		gracePeriod: gracePeriod,
+		outcomes:    make(map[txnKey]*txnOutcome),
+		unreported:  make(map[txnKey]*txnUnreported),
+	}
+	v.running.Add(2)
+	go v.consume(ctx, consumerClient)
+	go v.checkTicker(ctx, time.NewTicker(time.Second))
+	logger.Log.Info(fmt.Sprintln("transaction verifier started, grace period : ", gracePeriod))
+	return v, nil
+}
+
+// Close stops consuming and checking, and waits until both ended. Workers may
+// still report to a closed verifier.
+func (v *txnVerifier) Close() {
+	if v == nil {
+		return
+	}
+	v.cancel()
+	v.running.Wait()
+	logger.Log.Info("transaction verifier stopped")
+}
+
+// expand starts consuming the partitions topic.expand added, from their first
+// offset, so records the workers produce to them are verified too.
+func (v *txnVerifier) expand(partitions int) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	added := make(map[int32]kgo.Offset)
+	for p := v.partitions; p < int32(partitions); p++ {
+		added[p] = kgo.NewOffset().AtStart()
+	}
+	if len(added) == 0 {
+		return
+	}
+	v.consumer.AddConsumePartitions(map[string]map[int32]kgo.Offset{v.topic: added})
+	v.partitions = int32(partitions)
+	logger.Log.Info(fmt.Sprintf("transaction verifier consumes %d partitions of %s", partitions, v.topic))
+}
+
+func (v *txnVerifier) consume(ctx context.Context, client *kgo.Client) {
+	defer v.running.Done()
+	defer client.Close()
+	for {
+		fetches := client.PollFetches(ctx)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			logger.Log.Error(fmt.Sprintf("verifier fetch err: topic %s partition %d: %q", topic, partition, err))
+		})
+		fetches.EachRecord(v.observe)
+	}
+}
+
+// report is called by a worker once a transaction has been committed or aborted.
+func (v *txnVerifier) report(id string, seq uint64, records int64, committed bool) {
+	key := txnKey{id: id, seq: seq}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var seen int64
+	if u, ok := v.unreported[key]; ok {
+		seen = u.records
+		delete(v.unreported, key)
+	}
+	if !committed && seen > 0 {
+		v.abortedVisible += uint64(seen)
+		logger.Log.Error(fmt.Sprintf("aborted transaction is visible: transactional id %s sequence %d records %d", id, seq, seen))
+	}
+	v.outcomes[key] = &txnOutcome{
+		committed:  committed,
+		records:    records,
+		seen:       seen,
+		reportedAt: time.Now(),
+	}
+}
+
+// observe is called for every record readable with read_committed isolation.
+func (v *txnVerifier) observe(rec *kgo.Record) {
+	key, ok := recordTxnKey(rec)
+	if !ok {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	outcome, ok := v.outcomes[key]
+	if !ok {
+		u, ok := v.unreported[key]
+		if !ok {
+			u = &txnUnreported{seenAt: time.Now()}
+			v.unreported[key] = u
+		}
+		u.records++
+		return
+	}
+	outcome.seen++
+	if !outcome.committed {
+		v.abortedVisible++
+		logger.Log.Error(fmt.Sprintf("aborted transaction is visible: transactional id %s sequence %d partition %d offset %d", key.id, key.seq, rec.Partition, rec.Offset))
+	} else if outcome.seen > outcome.records {
+		v.duplicates++
+		logger.Log.Error(fmt.Sprintf("duplicate record in committed transaction: transactional id %s sequence %d partition %d offset %d", key.id, key.seq, rec.Partition, rec.Offset))
+	}
+}
+
+// check settles every reported transaction that is complete or past the grace
+// period, and forgets the unreported ones past the grace period.
+func (v *txnVerifier) check() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	for key, outcome := range v.outcomes {
+		expired := now.Sub(outcome.reportedAt) > v.gracePeriod
+		switch {
+		case outcome.committed && outcome.seen >= outcome.records:
+			v.verifiedTxns++
+			v.verifiedRecords += uint64(outcome.records)
+			delete(v.outcomes, key)
+		case outcome.committed && expired:
+			missing := outcome.records - outcome.seen
+			v.missingRecords += uint64(missing)
+			logger.Log.Error(fmt.Sprintf("committed transaction is not fully readable: transactional id %s sequence %d missing %d of %d records", key.id, key.seq, missing, outcome.records))
+			delete(v.outcomes, key)
+		case !outcome.committed && expired:
+			delete(v.outcomes, key)
+		}
+	}
+	for key, u := range v.unreported {
+		if now.Sub(u.seenAt) > v.gracePeriod {
+			delete(v.unreported, key)
+		}
+	}
+}
+
+/**********************************************************************
+**                                                                   **
+**                         Verifier metric print                     **
+**                                                                   **
+***********************************************************************/
+func (v *txnVerifier) checkTicker(ctx context.Context, ticker *time.Ticker) {
+	defer v.running.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		v.check()
+
+		v.mu.Lock()
+		logger.Log.Info(fmt.Sprintln("verified transactions : ", v.verifiedTxns))
+		logger.Log.Info(fmt.Sprintln("verified records : ", v.verifiedRecords))
+		if v.abortedVisible != 0 || v.missingRecords != 0 || v.duplicates != 0 {
+			logger.Log.Error(fmt.Sprintln("aborted records visible : ", v.abortedVisible))
+			logger.Log.Error(fmt.Sprintln("committed records missing : ", v.missingRecords))
+			logger.Log.Error(fmt.Sprintln("duplicate records : ", v.duplicates))
+		}
+		v.mu.Unlock()
+	}
+}
+
+func recordTxnKey(rec *kgo.Record) (txnKey, bool) {
+	var key txnKey
+	var hasId, hasSeq bool
+	for _, h := range rec.Headers {
+		switch h.Key {
+		case value.HEADER_TXN_ID:
+			key.id = string(h.Value)
+			hasId = true
+		case value.HEADER_TXN_SEQ:
+			seq, err := strconv.ParseUint(string(h.Value), 10, 64)
+			if err != nil {
+				return key, false
+			}
+			key.seq = seq
+			hasSeq = true
+		}
+	}
+	return key, hasId && hasSeq
+}
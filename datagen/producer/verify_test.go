@@ -0,0 +1,171 @@
+package producer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeConsumer records the partitions added to the verifier consumer.
+type fakeConsumer struct {
+	added []map[string]map[int32]kgo.Offset
+}
+
+func (c *fakeConsumer) AddConsumePartitions(partitions map[string]map[int32]kgo.Offset) {
+	c.added = append(c.added, partitions)
+}
+
+func newTestVerifier(partitions int32) (*txnVerifier, *fakeConsumer) {
+	consumer := &fakeConsumer{}
+	return &txnVerifier{
+		topic:       "datagen",
+		consumer:    consumer,
+		partitions:  partitions,
+		gracePeriod: time.Minute,
+		outcomes:    make(map[txnKey]*txnOutcome),
+		unreported:  make(map[txnKey]*txnUnreported),
+	}, consumer
+}
+
+func TestTxnVerifierExpand(t *testing.T) {
+	v, consumer := newTestVerifier(2)
+
+	v.expand(4)
+	v.expand(4) // already consumed
+	v.expand(3) // partitions never shrink
+
+	start := kgo.NewOffset().AtStart()
+	want := []map[string]map[int32]kgo.Offset{{"datagen": {2: start, 3: start}}}
+	if !reflect.DeepEqual(consumer.added, want) {
+		t.Errorf("added partitions %v, want %v", consumer.added, want)
+	}
+
+	// a transaction written to an added partition is verified once read
+	v.report("datagen-1", 0, 2, true)
+	for _, partition := range []int32{1, 3} {
+		v.observe(txnRecord("datagen-1", 0, partition))
+	}
+	v.check()
+	if v.verifiedTxns != 1 || v.missingRecords != 0 {
+		t.Errorf("verified %d transactions, %d missing records, want 1 and 0", v.verifiedTxns, v.missingRecords)
+	}
+
+	var nilVerifier *txnVerifier
+	nilVerifier.expand(8) // verify off
+}
+
+func txnRecord(id string, seq uint64, partition int32) *kgo.Record {
+	ts := &txnState{id: id, seq: seq, verifier: &txnVerifier{}}
+	rec := &kgo.Record{Partition: partition}
+	ts.stamp(rec)
+	return rec
+}
+
+func TestTxnVerifierCheck(t *testing.T) {
+	type event struct {
+		report    bool // report the outcome, or observe a record
+		committed bool
+		records   int64
+	}
+	tests := []struct {
+		name    string
+		events  []event
+		expire  bool      // the grace period passed before the check
+		want    [4]uint64 // verified records, aborted visible, missing, duplicates
+		pending bool      // the outcome is still waiting for records
+	}{
+		{
+			name:   "committed, read after report",
+			events: []event{{report: true, committed: true, records: 2}, {}, {}},
+			want:   [4]uint64{2, 0, 0, 0},
+		},
+		{
+			name:   "committed, read before report",
+			events: []event{{}, {}, {report: true, committed: true, records: 2}},
+			want:   [4]uint64{2, 0, 0, 0},
+		},
+		{
+			name:    "committed, partly read within grace period",
+			events:  []event{{report: true, committed: true, records: 3}, {}},
+			want:    [4]uint64{0, 0, 0, 0},
+			pending: true,
+		},
+		{
+			name:   "committed, partly read after grace period",
+			events: []event{{report: true, committed: true, records: 3}, {}},
+			expire: true,
+			want:   [4]uint64{0, 0, 2, 0},
+		},
+		{
+			name:   "committed, duplicate",
+			events: []event{{report: true, committed: true, records: 1}, {}, {}},
+			want:   [4]uint64{1, 0, 0, 1},
+		},
+		{
+			name:   "aborted, read before report",
+			events: []event{{}, {report: true, records: 1}},
+			want:   [4]uint64{0, 1, 0, 0},
+			// an aborted outcome waits out the grace period for late records
+			pending: true,
+		},
+		{
+			name:    "aborted, read after report",
+			events:  []event{{report: true, records: 2}, {}},
+			want:    [4]uint64{0, 1, 0, 0},
+			pending: true,
+		},
+		{
+			name:   "aborted, not read",
+			events: []event{{report: true, records: 2}},
+			expire: true,
+			want:   [4]uint64{0, 0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		v, _ := newTestVerifier(1)
+		for _, e := range tt.events {
+			if e.report {
+				v.report("datagen-1", 7, e.records, e.committed)
+			} else {
+				v.observe(txnRecord("datagen-1", 7, 0))
+			}
+		}
+		if tt.expire {
+			for _, outcome := range v.outcomes {
+				outcome.reportedAt = outcome.reportedAt.Add(-2 * v.gracePeriod)
+			}
+		}
+		v.check()
+		got := [4]uint64{v.verifiedRecords, v.abortedVisible, v.missingRecords, v.duplicates}
+		if got != tt.want {
+			t.Errorf("%s: verified, aborted visible, missing, duplicates = %v, want %v", tt.name, got, tt.want)
+		}
+		if pending := len(v.outcomes) > 0; pending != tt.pending {
+			t.Errorf("%s: pending %v, want %v", tt.name, pending, tt.pending)
+		}
+	}
+}
+
+func TestTxnVerifierUnreported(t *testing.T) {
+	v, _ := newTestVerifier(1)
+
+	// a transaction of a previous run is never reported
+	v.observe(txnRecord("datagen-1", 3, 0))
+	v.observe(txnRecord("datagen-2", 0, 0))
+	v.observe(&kgo.Record{}) // not produced in a verified transaction
+	if len(v.unreported) != 2 {
+		t.Fatalf("%d unreported transactions, want 2", len(v.unreported))
+	}
+	v.check()
+	if len(v.unreported) != 2 {
+		t.Errorf("unreported transactions expired within the grace period")
+	}
+
+	v.unreported[txnKey{id: "datagen-1", seq: 3}].seenAt = time.Now().Add(-2 * v.gracePeriod)
+	v.check()
+	if _, ok := v.unreported[txnKey{id: "datagen-2", seq: 0}]; !ok || len(v.unreported) != 1 {
+		t.Errorf("unreported transactions %v, want only datagen-2 0", v.unreported)
+	}
+}
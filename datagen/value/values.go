@@ -17,9 +17,80 @@ const (
 
 	MESSAGE_MODE_QUICKSTART    = "quickstart"
 	MESSAGE_MODE_MESSAGE_BYTES = "message-bytes"
+	MESSAGE_MODE_REPLAY        = "replay"
+	MESSAGE_MODE_MIRROR        = "mirror"
+	MESSAGE_MODE_RELATIONAL    = "relational"
+)
+
+const (
+	REPLAY_FORMAT_JSONL = "jsonl"
+	REPLAY_FORMAT_CSV   = "csv"
+	REPLAY_FORMAT_AVRO  = "avro"
+	REPLAY_FORMAT_KCAT  = "kcat"
+
+	REPLAY_AT_EOF_STOP = "stop"
+	REPLAY_AT_EOF_LOOP = "loop"
+)
+
+const (
+	MIRROR_START_EARLIEST = "earliest"
+	MIRROR_START_LATEST   = "latest"
+)
+
+// entities of the relational message mode, each produced to <topic.name>.<entity>
+const (
+	ENTITY_CUSTOMERS   = "customers"
+	ENTITY_PRODUCTS    = "products"
+	ENTITY_ORDERS      = "orders"
+	ENTITY_ORDER_ITEMS = "order_items"
+	ENTITY_PAYMENTS    = "payments"
+)
+
+// RELATIONAL_ENTITIES lists the entities parents first.
+var RELATIONAL_ENTITIES = []string{ENTITY_CUSTOMERS, ENTITY_PRODUCTS, ENTITY_ORDERS, ENTITY_ORDER_ITEMS, ENTITY_PAYMENTS}
+
+const (
+	TOPIC_IF_EXISTS_KEEP         = "keep"
+	TOPIC_IF_EXISTS_ALTER        = "alter"
+	TOPIC_IF_EXISTS_FAIL_ON_DIFF = "fail-on-diff"
+	TOPIC_IF_EXISTS_RECREATE     = "recreate"
+)
+
+const (
+	SINK_KAFKA  = "kafka"
+	SINK_STDOUT = "stdout"
+	SINK_FILE   = "file"
+
+	SINK_FORMAT_JSONL  = "jsonl"
+	SINK_FORMAT_PRETTY = "pretty"
+)
+
+const (
+	EXPORT_FORMAT_JSONL   = "jsonl"
+	EXPORT_FORMAT_CSV     = "csv"
+	EXPORT_FORMAT_AVRO    = "avro"
+	EXPORT_FORMAT_PARQUET = "parquet"
 )
 
 const (
 	SCHEMA_REGISTRY_MEESAGE_TYPE_AVRO      = "avro"
 	SCHEMA_REGISTRY_MEESAGE_TYPE_PROTOUBUF = "protobuf"
+	SCHEMA_REGISTRY_MEESAGE_TYPE_JSON      = "json"
+
+	SCHEMA_REGISTRY_VERSION_LATEST = "latest"
+)
+
+const (
+	SASL_PLAIN         = "PLAIN"
+	SASL_SCRAM_SHA_256 = "SCRAM-SHA-256"
+	SASL_SCRAM_SHA_512 = "SCRAM-SHA-512"
+	SASL_OAUTHBEARER   = "OAUTHBEARER"
+	SASL_GSSAPI        = "GSSAPI"
+	SASL_AWS_MSK_IAM   = "AWS_MSK_IAM"
+)
+
+const (
+	HEADER_TXN_ID  = "datagen-txn-id"
+	HEADER_TXN_SEQ = "datagen-txn-seq"
+	HEADER_SEQ     = "datagen-seq" // <worker>-<sequence number>, with datagen.sequence-header
 )
@@ -10,26 +10,15 @@ export PRODUCER_LINGERS=0
 export PRODUCER_COMPRESSION__TYPE=snappy
 
 ## SASL SCRAM
-export PRODUCER_SASL__MECHANISM=SCRAM-SHA-512
-export PRODUCER_SASL__USERNAME=admin
-export PRODUCER_SASL__PASSWORD=admin-secret
-
-## SASL OAUTHBEARER
-export PRODUCER_SASL__MECHANISM=OAUTHBEARER
-export PRODUCER_SASL__CLIENT__ID=test
-export PRODUCER_SASL__CLIENT__SECRET=test2
-export PRODUCER_SASL__TOKEN__ENDPOINT=test3
-
-## SASL AWS__MSK__IAM
-export PRODUCER_SASL__MECHANISM=AWS_MSK_IAM
-export PRODUCER_SASL__AWS__ACCESS__KEY__ID=test1
-export PRODUCER_SASL__AWS__SECRET__ACCESS__KEY=test2
+export PRODUCER_SASL_MECHANISM=SCRAM-SHA-512
+export PRODUCER_SASL_USERNAME=admin
+export PRODUCER_SASL_PASSWORD=admin-secret
 
 ## TLS
-export PRODUCER_TLS__CAFILE=cafile
-export PRODUCER_TLS__CERTFILE=certfile
-export PRODUCER_TLS__KEYFILE=keyfile
-export PRODUCER_TLS__SKIPVERIFY=skipverify
+# export PRODUCER_TLS_CAFILE=cafile
+# export PRODUCER_TLS_CERTFILE=certfile
+# export PRODUCER_TLS_KEYFILE=keyfile
+export PRODUCER_TLS_SKIPVERIFY=true
 
 ## TOPIC
 export TOPIC_NAME=test
@@ -37,13 +26,11 @@ export TOPIC_PARTITION=6
 export TOPIC_REPLICA__FACTOR=1
 
 ## DATAGEN
-export DATAGEN_QUICKSTART=user
-export DATAGEN_MESSAGE__BYTES=100
 export DATAGEN_GO__ROUTINE=1
-export DATAGEN_RATE__PER__SECOND=100
-export DATAGEN_INTERVAL=100
-export DATAGEN_DATA_RATE_LIMIT_BPS=100000
-
-# ./gomplate -f ./in/datagen.yaml.tmpl -o ./datagen.yaml
+export DATAGEN_PRODUCE_MODE=rate-per-second
+export DATAGEN_PRODUCE_RATE__PER__SECOND=100
+export DATAGEN_MESSAGE_MODE=quickstart
+export DATAGEN_MESSAGE_QUICKSTART=user
 
-echo ${!DATAGEN*} ${!TOPIC*} ${!PRODUCER*} ${!BOOTSTRAP*} | ./gomplate -d config=stdin: -f ./in/datagen.yaml.tmpl -o ./datagen.yaml
\ No newline at end of file
+## print the effective config (secrets are redacted)
+../../datagen print-config -config=""
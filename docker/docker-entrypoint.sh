@@ -8,9 +8,14 @@
 set -e
 umask 0002
 
-echo ${!DATAGEN*} ${!TOPIC*} ${!PRODUCER*} ${!BOOTSTRAP*} | /config/gomplate -d config=stdin: -f /config/in/datagen.yaml.tmpl -o /config/datagen.yaml
+## config : a mounted /config/datagen.yaml is used as the base layer,
+## DATAGEN*, TOPIC*, PRODUCER*, BOOTSTRAP* environment variables override it
+CONFIG_FILE=""
+if [ -f /config/datagen.yaml ]; then
+    CONFIG_FILE=/config/datagen.yaml
+fi
 
 ## execute
-umask 0755 && exec /app/datagen -config=/config/datagen.yaml
+umask 0755 && exec /app/datagen -config="${CONFIG_FILE}" "$@"
 ## ----------
 ## END OF FILE
@@ -2,14 +2,85 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	_ "net/http/pprof"
+	"os"
 	"spitha/datagen/datagen"
+	"spitha/datagen/datagen/config"
 )
 
 func main() {
 
-	configPath := flag.String("config", "datagen.yaml", "Input config file")
-	flag.Parse()
+	// subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			configPath, overrides := parseFlags("validate", os.Args[2:])
+			datagen.Validate(configPath, overrides)
+			return
+		case "print-config":
+			configPath, overrides := parseFlags("print-config", os.Args[2:])
+			datagen.PrintConfig(configPath, overrides)
+			return
+		case "preflight":
+			configPath, overrides := parseFlags("preflight", os.Args[2:])
+			datagen.Preflight(configPath, overrides)
+			return
+		case "export":
+			configPath, overrides := parseFlags("export", os.Args[2:])
+			datagen.Export(configPath, overrides)
+			return
+		case "ctl":
+			fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+			fs.Usage = usage
+			addr := fs.String("addr", "localhost:8080", "Control api address of the running datagen (control.listen)")
+			token := fs.String("token", os.Getenv(config.ENV_PREFIX+"CONTROL_TOKEN"), "Control api bearer token (control.token)")
+			fs.Parse(os.Args[2:])
+			if fs.NArg() == 0 {
+				usage()
+				os.Exit(2)
+			}
+			datagen.Ctl(*addr, *token, fs.Arg(0), fs.Args()[1:])
+			return
+		case "help", "-h", "-help", "--help":
+			usage()
+			return
+		}
+	}
 
-	datagen.Handler(*configPath)
+	configPath, overrides := parseFlags("datagen", os.Args[1:])
+	datagen.Handler(configPath, overrides)
+}
+
+// parseFlags parses -config and one flag per config key, e.g. --topic.partition=6.
+func parseFlags(name string, args []string) (string, config.Overrides) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = usage
+	configPath := fs.String("config", "datagen.yaml", "Input config file, empty to use only environment variables and flags")
+	overrides := config.Overrides{}
+	overrides.RegisterFlags(fs)
+	fs.Parse(args)
+	return *configPath, overrides
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  datagen [-config datagen.yaml] [--<key>=<value>...]               generate data")
+	fmt.Fprintln(os.Stderr, "  datagen validate [-config datagen.yaml] [--<key>=<value>...]      check the config and print every problem")
+	fmt.Fprintln(os.Stderr, "  datagen print-config [-config datagen.yaml] [--<key>=<value>...]  print the effective config with secrets redacted")
+	fmt.Fprintln(os.Stderr, "  datagen preflight [-config datagen.yaml] [--<key>=<value>...]     check the cluster can take the run")
+	fmt.Fprintln(os.Stderr, "  datagen export [-config datagen.yaml] [--<key>=<value>...]        write export.records values to files")
+	fmt.Fprintln(os.Stderr, "  datagen ctl [-addr localhost:8080] [-token TOKEN] <command>       control a running datagen (control.listen)")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Control commands:")
+	fmt.Fprintln(os.Stderr, "  status                    print the state of the workers and the produce settings")
+	fmt.Fprintln(os.Stderr, "  pause [worker]            pause every worker or a single one")
+	fmt.Fprintln(os.Stderr, "  resume [worker]           resume every worker or a single one")
+	fmt.Fprintln(os.Stderr, "  set <key>=<value>...      change rate, bps, interval, jitter, workers or any datagen.* key")
+	fmt.Fprintln(os.Stderr, "  burst <factor> <duration> multiply the produce rate for a while, e.g. burst 5 30s")
+	fmt.Fprintln(os.Stderr, "  stop                      commit open transactions, flush and exit")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Every config key can be set with a flag such as --topic.partition=6 or an environment")
+	fmt.Fprintln(os.Stderr, "variable such as DATAGEN_TOPIC_PARTITION=6. Flags override environment variables,")
+	fmt.Fprintln(os.Stderr, "which override the config file.")
 }